@@ -0,0 +1,272 @@
+package autotile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/voidshard/tile"
+)
+
+// Frame is a single frame of an animated tile
+type Frame struct {
+	// Src is the image shown for this frame
+	Src string
+
+	// Duration this frame is shown for (Tiled uses milliseconds)
+	Duration time.Duration
+}
+
+// animation is attached to events setting animated tiles
+type animation struct {
+	frames []Frame
+
+	// properties for the frame tiles (ie. without the animation)
+	props *tile.Properties
+}
+
+// formatAnimation returns frames as we write them to the "animation" property,
+// a comma separated list of src:milliseconds (see parseAnimation)
+func formatAnimation(frames []Frame) string {
+	parts := make([]string, len(frames))
+	for i, f := range frames {
+		parts[i] = fmt.Sprintf("%s:%d", f.Src, f.Duration.Milliseconds())
+	}
+	return strings.Join(parts, ",")
+}
+
+// animated returns the Tilesets of `tiles` that have Animations, in field order.
+// Most LandTiles have none, in which case this doesn't allocate.
+func animated(tiles *LandTiles) []*Tileset {
+	if tiles == nil {
+		return nil
+	}
+
+	var found []*Tileset
+	v := reflect.ValueOf(tiles).Elem()
+	for _, i := range tilesetIndexes {
+		ts := v.Field(i).Interface().(*Tileset)
+		if ts != nil && len(ts.Animations) > 0 {
+			found = append(found, ts)
+		}
+	}
+	return found
+}
+
+// parseAnimation returns the frames written by formatAnimation
+func parseAnimation(value string) ([]Frame, error) {
+	frames := []Frame{}
+	for _, part := range strings.Split(value, ",") {
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("%s: animation frame %q has no duration", ErrInvalidValue, part)
+		}
+		ms, err := strconv.ParseInt(part[i+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: animation frame %q duration: %v", ErrInvalidValue, part, err)
+		}
+		frames = append(frames, Frame{Src: part[:i], Duration: time.Duration(ms) * time.Millisecond})
+	}
+	return frames, nil
+}
+
+// EncodeTMX writes `m` as Tiled TMX, as m.Encode, but with the "animation" property
+// of animated tiles (see Tileset.Animations) written as a Tiled <animation> element.
+// Use this (or WriteTMXFile) rather than m.Encode for animations to play in Tiled.
+func EncodeTMX(m *tile.Map, w io.Writer) error {
+	buff := bytes.Buffer{}
+	err := m.Encode(&buff)
+	if err != nil {
+		return err
+	}
+
+	// the tile library only supports one tileset (see tile.Map.Encode)
+	ids := map[string]uint{}
+	if len(m.Tilesets) > 0 {
+		for _, t := range m.Tilesets[0].Tiles {
+			if t.Image != nil {
+				ids[t.Image.Source] = t.ID
+			}
+		}
+	}
+
+	dec := xml.NewDecoder(&buff)
+	enc := xml.NewEncoder(w)
+
+	var frames []Frame
+	inTile := false
+	skip := 0 // depth of the animation property we're dropping
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if skip > 0 {
+			switch tok.(type) {
+			case xml.StartElement:
+				skip++
+			case xml.EndElement:
+				skip--
+			}
+			continue
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "tile":
+				inTile, frames = true, nil
+			case "property":
+				if !inTile || attr(el, "name") != pAnimation {
+					break
+				}
+				frames, err = parseAnimation(attr(el, "value"))
+				if err != nil {
+					return err
+				}
+				skip = 1
+				continue
+			}
+		case xml.EndElement:
+			if el.Name.Local == "tile" && inTile {
+				inTile = false
+				err = encodeAnimation(enc, ids, frames)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = enc.EncodeToken(xml.CopyToken(tok))
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.Flush()
+}
+
+// WriteTMXFile writes `m` to the given file with EncodeTMX
+func WriteTMXFile(m *tile.Map, fname string) error {
+	buff := bytes.Buffer{}
+	err := EncodeTMX(m, &buff)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, buff.Bytes(), 0644)
+}
+
+// encodeAnimation writes a Tiled <animation> element for the given frames, `ids`
+// are the tileset ids of each tile by src.
+func encodeAnimation(enc *xml.Encoder, ids map[string]uint, frames []Frame) error {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	anim := xml.StartElement{Name: xml.Name{Local: "animation"}}
+	err := enc.EncodeToken(anim)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		id, ok := ids[f.Src]
+		if !ok {
+			return fmt.Errorf("%s: animation frame %s is not in the tileset", ErrMissingRequiredValue, f.Src)
+		}
+		frame := xml.StartElement{Name: xml.Name{Local: "frame"}, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "tileid"}, Value: strconv.FormatUint(uint64(id), 10)},
+			{Name: xml.Name{Local: "duration"}, Value: strconv.FormatInt(f.Duration.Milliseconds(), 10)},
+		}}
+		err = enc.EncodeToken(frame)
+		if err != nil {
+			return err
+		}
+		err = enc.EncodeToken(frame.End())
+		if err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(anim.End())
+}
+
+// attr returns the value of the named attribute of `el`
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// animate adds animation properties to any events setting animated tiles.
+// If more than one Tileset animates a tile, the first (in LandTiles order) is used.
+func (a *Autotiler) animate(tiles *LandTiles, evts []*Event) {
+	anims := animated(tiles)
+	if len(anims) == 0 {
+		return
+	}
+	for _, e := range evts {
+		if e.Src == "" {
+			continue
+		}
+		var frames []Frame
+		for _, ts := range anims {
+			if found, ok := ts.Animations[e.Src]; ok {
+				frames = found
+				break
+			}
+		}
+		if len(frames) == 0 {
+			continue
+		}
+		props := tile.NewProperties().Merge(e.Properties)
+		props.SetString(pAnimation, formatAnimation(frames))
+		e.anim = &animation{frames: frames, props: e.Properties}
+		e.Properties = props
+	}
+}
+
+// setTile sets the tile for the event `e` in `t` (along with it's properties &
+// any animation frames)
+func setTile(t tile.Tileable, e *Event) error {
+	err := t.Set(e.X, e.Y, e.Z, e.Src)
+	if err != nil {
+		return err
+	}
+	if e.Src == "" || e.Properties == nil {
+		return nil
+	}
+	err = t.SetProperties(e.Src, e.Properties)
+	if err != nil {
+		return err
+	}
+	if e.anim == nil {
+		return nil
+	}
+
+	// make sure the frames are in the tileset too
+	for _, f := range e.anim.frames {
+		if f.Src == e.Src || f.Src == "" {
+			continue
+		}
+		props := e.anim.props
+		if props == nil {
+			props = tile.NewProperties()
+		}
+		err = t.SetProperties(f.Src, props)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
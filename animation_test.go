@@ -0,0 +1,131 @@
+package autotile
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voidshard/tile"
+)
+
+func TestEncodeTMXAnimation(t *testing.T) {
+	frames := []Frame{
+		{Src: "water.0.png", Duration: 100 * time.Millisecond},
+		{Src: "water.1.png", Duration: 250 * time.Millisecond},
+	}
+	tiles := &LandTiles{Water: &Tileset{
+		Full:       []string{"water.0.png"},
+		Animations: map[string][]Frame{"water.0.png": frames},
+	}}
+
+	m := tile.New(&tile.Config{TileWidth: 16, TileHeight: 16, MapWidth: 2, MapHeight: 2})
+	evts := []*Event{
+		newEvent(0, 0, 0, "water.0.png", propertiesWater),
+		newEvent(1, 0, 0, "sand.png", nil),
+	}
+	(&Autotiler{}).animate(tiles, evts)
+	for _, e := range evts {
+		err := setTile(m, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buff := bytes.Buffer{}
+	err := EncodeTMX(m, &buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buff.String()
+
+	ids := map[string]uint{}
+	for _, tl := range m.Tilesets[0].Tiles {
+		ids[tl.Image.Source] = tl.ID
+	}
+	want := fmt.Sprintf(`<animation><frame tileid="%d" duration="100"></frame><frame tileid="%d" duration="250"></frame></animation>`, ids["water.0.png"], ids["water.1.png"])
+	if !strings.Contains(out, want) {
+		t.Errorf("expected %s in output, got %s", want, out)
+	}
+	if n := strings.Count(out, "<animation>"); n != 1 {
+		t.Errorf("expected 1 animation, got %d", n)
+	}
+	if strings.Contains(out, `name="animation"`) {
+		t.Errorf("expected animation property to be replaced, got %s", out)
+	}
+
+	_, err = tile.Decode(&buff)
+	if err != nil {
+		t.Errorf("failed to decode written map: %v", err)
+	}
+}
+
+func TestParseAnimation(t *testing.T) {
+	frames := []Frame{{Src: "a.png", Duration: time.Second}, {Src: "b.png", Duration: 5 * time.Millisecond}}
+
+	got, err := parseAnimation(formatAnimation(frames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(frames) {
+		t.Errorf("expected %v got %v", frames, got)
+	}
+
+	_, err = parseAnimation("a.png")
+	if err == nil {
+		t.Error("expected error for frame without duration")
+	}
+}
+
+func TestReplayAnimation(t *testing.T) {
+	frames := []Frame{
+		{Src: "water.0.png", Duration: 100 * time.Millisecond},
+		{Src: "water.1.png", Duration: 100 * time.Millisecond},
+	}
+	tiles := &LandTiles{Water: &Tileset{
+		Full:       []string{"water.0.png"},
+		Animations: map[string][]Frame{"water.0.png": frames},
+	}}
+
+	evts := []*Event{newEvent(0, 0, 0, "water.0.png", propertiesWater)}
+	(&Autotiler{}).animate(tiles, evts)
+
+	buff := bytes.Buffer{}
+	rec := NewEventRecorder(&buff)
+	for _, e := range evts {
+		err := rec.Record(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := tile.New(&tile.Config{TileWidth: 16, TileHeight: 16, MapWidth: 2, MapHeight: 2})
+	err := Replay(&buff, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.Buffer{}
+	err = EncodeTMX(m, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "<frame "); n != 2 {
+		t.Errorf("expected 2 animation frames, got %d in %s", n, out.String())
+	}
+}
+
+func TestAnimateFirstTilesetWins(t *testing.T) {
+	tiles := &LandTiles{
+		Water: &Tileset{Animations: map[string][]Frame{"a.png": {{Src: "water.png"}}}},
+		Lava:  &Tileset{Animations: map[string][]Frame{"a.png": {{Src: "lava.png"}}}},
+	}
+	for i := 0; i < 20; i++ {
+		evts := []*Event{newEvent(0, 0, 0, "a.png", nil)}
+		(&Autotiler{}).animate(tiles, evts)
+		if evts[0].anim == nil || evts[0].anim.frames[0].Src != "water.png" {
+			t.Fatalf("expected the Water animation, got %+v", evts[0].anim)
+		}
+	}
+}
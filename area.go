@@ -125,6 +125,19 @@ func (n *nearby) all() []*area {
 	}
 }
 
+// at returns the nearby area at the offset (dx, dy) from the Centre, if any
+func (n *nearby) at(dx, dy int) *area {
+	if dx == 0 && dy == 0 {
+		return n.Centre
+	}
+	for _, a := range n.all() {
+		if a.X == n.Centre.X+dx && a.Y == n.Centre.Y+dy {
+			return a
+		}
+	}
+	return nil
+}
+
 // cardinals returns information on surrounding tiles
 func cardinals(o Outline, tx, ty int) *nearby {
 	return &nearby{
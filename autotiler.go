@@ -1,13 +1,18 @@
 package autotile
 
 import (
-	"fmt"
+	"context"
 	"image"
 	"math/rand"
+	"sync"
 
 	"github.com/voidshard/tile"
 )
 
+// ProgressFunc is informed as work is done; `done` is the number of tiles processed
+// so far out of `total`.
+type ProgressFunc func(done, total int)
+
 // fnPlacements is used internal to reference the various "placeX" functions
 type fnPlacements func(Outline, *rand.Rand, *area, bool) ([]*Event, string, error)
 
@@ -17,47 +22,42 @@ type fnPlacements func(Outline, *rand.Rand, *area, bool) ([]*Event, string, erro
 type Autotiler struct {
 	cfg *Config
 
-	evt chan *Event
-	out chan *Event
+	// placement passes, in the order they're run
+	passes []*placementPass
+
+	// features (waterfalls, stairs ..) placed after land
+	features []*namedFeature
+
+	// event subscribers
+	subLock    sync.RWMutex
+	subs       map[*subscriber]bool
+	closed     bool
+	closing    chan struct{}
+	closeOnce  sync.Once
+	events     <-chan *Event
+	eventsOnce sync.Once
+
+	// number of runs so far
+	runs uint64
+
+	// layers we've set land tiles on (see Retile)
+	layers sync.Map
 }
 
 // NewAutotiler creates & returns an autotiler object.
 func NewAutotiler(cfg *Config) (*Autotiler, error) {
-	at := &Autotiler{cfg: cfg, evt: make(chan *Event)}
+	at := &Autotiler{cfg: cfg, subs: map[*subscriber]bool{}, closing: make(chan struct{})}
+	at.passes = at.defaultPasses()
+	at.features = at.defaultFeatures()
 
 	err := cfg.Validate()
 	if err != nil {
 		return nil, err
 	}
 
-	go func() { // event pump
-		for e := range at.evt {
-			if at.out == nil {
-				// drop event if no one is listening
-				continue
-			}
-			at.out <- e
-		}
-	}()
-
 	return at, nil
 }
 
-// emitEvent pushes to our internal event chan
-func (a *Autotiler) emitEvent(e *Event) {
-	a.evt <- e
-}
-
-// Events allows a user to see what the autotiler is placing where
-// as the decisions are made.
-// If Events() is called the caller must consume events.
-func (a *Autotiler) Events() <-chan *Event {
-	if a.out == nil {
-		a.out = make(chan *Event)
-	}
-	return a.out
-}
-
 // SetLand uses data from the Outline `o` to place tiles for the region `region` on the
 // given Tileable map `t`
 // This sets
@@ -65,175 +65,183 @@ func (a *Autotiler) Events() <-chan *Event {
 // - water, lava
 // - cliffs, waterfalls
 func (a *Autotiler) SetLand(o Outline, region image.Rectangle, t tile.Tileable) error {
+	return a.SetLandContext(context.Background(), o, region, t, nil)
+}
+
+// SetLandContext is SetLand but returns early with the context error if `ctx` is
+// cancelled. If `progress` is given it's called as rows of the region are completed.
+func (a *Autotiler) SetLandContext(ctx context.Context, o Outline, region image.Rectangle, t tile.Tileable, progress ProgressFunc) error {
+	r := a.newRun(region)
+	defer a.finish(r)
+
+	return a.setLand(ctx, o, t, r, progress)
+}
+
+// setLand places land tiles for the given run
+func (a *Autotiler) setLand(ctx context.Context, o Outline, t tile.Tileable, r *run, progress ProgressFunc) error {
+	region := r.region
+
 	// for some objects that involve intersections of tiles we mark collision
 	// squares and come back to them later
 	collisions := newCollisionHandler()
 
-	enact := func(events []*Event) error {
-		if events == nil {
-			return nil // nothing to do
-		}
+	// if we can, fetch everything we'll need up front
+	o = a.prefetch(o, region.Inset(-a.collisionMargin()))
 
-		for _, e := range events {
-			if e.collisionType != "" {
-				collisions.append(e)
-				continue // collisions are internal information
-			}
-			if e.Src == "" && e.ObjectID == "" {
-				continue // nothing is set
-			}
-			if e.X < region.Min.X || e.X >= region.Max.X || e.Y < region.Min.Y && e.Y >= region.Max.Y {
-				continue // outside of the area
+	total := region.Dx() * region.Dy()
+	done := 0
+
+	for ty := region.Min.Y; ty < region.Max.Y; ty++ {
+		for tx := region.Min.X; tx < region.Max.X; tx++ {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
 
-			err := t.Set(e.X, e.Y, e.Z, e.Src)
+			evts, err := a.placeTile(o, tx, ty)
 			if err != nil {
 				return err
 			}
-			err = t.SetProperties(e.Src, e.Properties)
+			err = a.enact(t, r, collisions, evts)
 			if err != nil {
 				return err
 			}
+		}
 
-			a.emitEvent(e) // now that we've done it, push to listener (if any)
+		done += region.Dx()
+		if progress != nil {
+			progress(done, total)
 		}
-		return nil
 	}
 
-	placementFuncs := []fnPlacements{a.placeNull, a.placeLand, a.placeWater, a.placeRoad, a.placeMolten, a.placeCliffs}
+	err := a.detectCollisions(ctx, o, region, collisions)
+	if err != nil {
+		return err
+	}
 
-	seed := a.cfg.Seed + int64(region.Min.X)*int64(region.Max.Y) - int64(region.Max.X)*int64(region.Min.Y)
-	rng := rand.New(rand.NewSource(seed))
-	var err error
-	var evts []*Event
+	return a.resolveCollisions(o, r, t, collisions)
+}
 
-	for ty := region.Min.Y; ty < region.Max.Y; ty++ {
-		for tx := region.Min.X; tx < region.Max.X; tx++ {
-			me := newArea(o, tx, ty)
-			for _, pfn := range placementFuncs {
-				evts, _, err = pfn(o, rng, me, false)
+// placeTile runs all of our placement passes for the tile (tx, ty) & returns
+// the resulting events, in order.
+// Each pass is given it's own random number generator seeded from
+// (seed, tx, ty, pass) so that a tile comes out the same no matter which
+// region it was placed as part of.
+func (a *Autotiler) placeTile(o Outline, tx, ty int) ([]*Event, error) {
+	site := newSite(o, a.cfg, newArea(o, tx, ty))
+	all := []*Event{}
+	for _, p := range a.passes {
+		evts, _, err := p.placer.Place(tileRand(a.cfg.Seed, tx, ty, p.name), site, false)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, evts...)
+	}
+	all = append(all, a.detectFeatures(site)...)
+	a.animate(site.Data.Tiles(), all)
+	a.seenLayers(all)
+
+	return all, nil
+}
+
+// detectCollisions looks for collisions in a border collisionMargin tiles wide around
+// `region`. Tiles here aren't set, but it means that collisions that cross the region
+// edge are sized the same as they would be if the whole area was set at once.
+func (a *Autotiler) detectCollisions(ctx context.Context, o Outline, region image.Rectangle, collisions *collisionHandler) error {
+	outer := region.Inset(-a.collisionMargin())
+	strips := []image.Rectangle{
+		image.Rect(outer.Min.X, outer.Min.Y, outer.Max.X, region.Min.Y),   // top
+		image.Rect(outer.Min.X, region.Min.Y, region.Min.X, region.Max.Y), // left
+		image.Rect(region.Max.X, region.Min.Y, outer.Max.X, region.Max.Y), // right
+		image.Rect(outer.Min.X, region.Max.Y, outer.Max.X, outer.Max.Y),   // bottom
+	}
+
+	for _, strip := range strips {
+		so := a.prefetch(o, strip)
+
+		for ty := strip.Min.Y; ty < strip.Max.Y; ty++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for tx := strip.Min.X; tx < strip.Max.X; tx++ {
+				evts, err := a.placeTile(so, tx, ty)
 				if err != nil {
 					return err
 				}
-				err = enact(evts)
-				if err != nil {
-					return err
+				for _, e := range evts {
+					if e.collisionType != "" {
+						collisions.append(e)
+					}
 				}
 			}
 		}
 	}
 
-	for _, col := range collisions.All() {
-		evts = a.handleCollision(o, rng, col)
-		err = enact(evts)
-		if err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
 
-func (a *Autotiler) handleCollision(o Outline, rng *rand.Rand, col *collision) []*Event {
-	e := col.Events()[0]
-	me := newArea(o, e.X, e.Y)
-	tiles := me.Data.Tiles()
-	r := col.Max()
-
-	evts := []*Event{}
-
-	fmt.Println("DETECTED", col.typ, r)
-
-	switch col.typ {
-	case collisionStairsNS:
-		if tiles.StairsNorthSouth == nil {
-			return nil
+// enact writes the given events to `t`, handing collision events to `collisions`
+// for later processing.
+func (a *Autotiler) enact(t tile.Tileable, r *run, collisions *collisionHandler, events []*Event) error {
+	region := r.region
+	for _, e := range events {
+		if e.collisionType != "" {
+			collisions.append(e)
+			continue // collisions are internal information
 		}
-		evts = tiles.StairsNorthSouth.fillRect(
-			rng,
-			image.Rect(r.Min.X, r.Min.Y-1, r.Max.X, r.Max.Y+1),
-			a.cfg.ZOffsetWaterfall,
-			propertiesRoad,
-		)
-	case collisionStairsSN:
-		if tiles.StairsSouthNorth == nil {
-			return nil
+		if e.Src == "" && e.ObjectID == "" {
+			continue // nothing is set
 		}
-		evts = tiles.StairsSouthNorth.fillRect(rng, r, a.cfg.ZOffsetWaterfall, propertiesRoad)
-	case collisionStairsEW:
-		if tiles.StairsEastWest == nil {
-			return nil
+		if e.X < region.Min.X || e.X >= region.Max.X || e.Y < region.Min.Y || e.Y >= region.Max.Y {
+			continue // outside of the area
 		}
-		evts = tiles.StairsEastWest.fillRect(
-			rng,
-			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y-1),
-			a.cfg.ZOffsetWaterfall,
-			propertiesRoad,
-		)
-	case collisionStairsWE:
-		if tiles.StairsWestEast == nil {
-			return nil
+		if r.plan != nil {
+			r.plan.Events = append(r.plan.Events, e)
+			continue // we're only planning
 		}
-		evts = tiles.StairsWestEast.fillRect(
-			rng,
-			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y-1),
-			a.cfg.ZOffsetWaterfall,
-			propertiesRoad,
-		)
-	case collisionWaterfallNS:
-		if tiles.WaterfallNorthSouth == nil {
-			return nil
-		}
-		evts = tiles.WaterfallNorthSouth.fillRect(rng, r, a.cfg.ZOffsetWaterfall, propertiesWFall)
-	case collisionWaterfallSN:
-		if tiles.WaterfallSouthNorth == nil {
-			return nil
+
+		err := setTile(t, e)
+		if err != nil {
+			return err
 		}
-		evts = tiles.WaterfallSouthNorth.fillRect(
-			rng,
-			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y+1),
-			a.cfg.ZOffsetWaterfall,
-			propertiesWFall,
-		)
-	case collisionWaterfallEW:
-		if tiles.WaterfallEastWest == nil {
-			return nil
+
+		a.emit(r, e) // now that we've done it, push to listener (if any)
+	}
+	return nil
+}
+
+// resolveCollisions places tiles for all collisions found while placing land
+func (a *Autotiler) resolveCollisions(o Outline, r *run, t tile.Tileable, collisions *collisionHandler) error {
+	for _, col := range collisions.All() {
+		evts, err := a.handleCollision(o, col)
+		if err != nil {
+			return err
 		}
-		evts = tiles.WaterfallEastWest.fillRect(
-			rng,
-			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y),
-			a.cfg.ZOffsetWaterfall,
-			propertiesWFall,
-		)
-	case collisionWaterfallWE:
-		if tiles.WaterfallWestEast == nil {
-			return nil
+		err = a.enact(t, r, collisions, evts)
+		if err != nil {
+			return err
 		}
-		evts = tiles.WaterfallWestEast.fillRect(
-			rng,
-			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y),
-			a.cfg.ZOffsetWaterfall,
-			propertiesWFall,
-		)
 	}
-
-	return evts
+	return nil
 }
 
 func (a *Autotiler) placeNull(o Outline, rng *rand.Rand, me *area, tagsonly bool) ([]*Event, string, error) {
 	if !me.Data.IsNull() {
 		return nil, "", nil
 	}
+	tiles := me.Data.Tiles()
+	tag := Null
+	if bridgeFor(me.Data, tiles) != nil {
+		tag = "" // there's a bridge over us, see placeRoad
+	}
 	if tagsonly {
-		return nil, Null, nil
+		return nil, tag, nil
 	}
 
-	tiles := me.Data.Tiles()
 	if tiles == nil || tiles.Null == "" {
-		return nil, Null, nil
+		return nil, tag, nil
 	}
 
-	return []*Event{newEvent(me.X, me.Y, a.cfg.ZOffsetLand, tiles.Null, propertiesNull)}, Null, nil
+	return []*Event{newEvent(me.X, me.Y, a.cfg.ZOffsetLand, tiles.Null, propertiesNull)}, tag, nil
 }
 
 func (a *Autotiler) placeLand(o Outline, rng *rand.Rand, me *area, tagsonly bool) ([]*Event, string, error) {
@@ -242,49 +250,28 @@ func (a *Autotiler) placeLand(o Outline, rng *rand.Rand, me *area, tagsonly bool
 		return nil, "", nil
 	}
 
-	src := firstFull(rng, tiles.Grass, tiles.Dirt, tiles.Rock)
-	tag := Grass
-	srcT := ""
+	// we only look for water if a biome needs it
+	shore := -1
+	shoreFn := func() int {
+		if shore < 0 {
+			max := a.maxShore() + 1
+			shore = shoreDistance(o, me.X, me.Y, max)
+		}
+		return shore
+	}
 
-	beach := a.cfg.BeachWidth
-	nearWtr := false
-	nearWtrPlus := false
-	if beach > 0 && me.Data.Height() < a.cfg.CliffLevel {
-		nearWtr = len(withinRadius(o, me.X, me.Y, beach, func(in *area) bool { return in.Data.IsWater() })) > 0
-		nearWtrPlus = len(withinRadius(o, me.X, me.Y, beach+1, func(in *area) bool { return in.Data.IsWater() })) > 0
-	}
-	tsn := a.cfg.TransitionWidth
-
-	if me.Data.Temperature() <= a.cfg.SnowLevel-tsn {
-		src = firstFull(rng, tiles.Snow, tiles.Dirt, tiles.Rock)
-		tag = Snow
-	} else if nearWtr {
-		src = firstFull(rng, tiles.Sand, tiles.Rock)
-		tag = Sand
-	} else if me.Data.Height() >= a.cfg.MountainLevel+tsn {
-		src = firstFull(rng, tiles.Rock, tiles.Dirt)
-		tag = Rock
-	} else if me.Data.Temperature() <= a.cfg.VegetationMinTemp-tsn {
-		src = firstFull(rng, tiles.Dirt, tiles.Rock)
-		tag = Dirt
-	} else if me.Data.Temperature() >= a.cfg.VegetationMaxTemp+tsn { // desert
-		src = firstFull(rng, tiles.Sand, tiles.Rock, tiles.Dirt)
-		tag = Sand
+	full := a.chooseBiome(me, shoreFn, false)
+	if full == nil {
+		return nil, "", nil
 	}
 	if tagsonly {
-		return nil, tag, nil
+		return nil, full.tag(), nil
 	}
 
-	if me.Data.Temperature() <= a.cfg.SnowLevel {
-		srcT = firstTransition(rng, tiles.Snow, tiles.Dirt, tiles.Rock)
-	} else if nearWtrPlus && !nearWtr {
-		srcT = firstTransition(rng, tiles.Sand, tiles.Dirt)
-	} else if me.Data.Temperature() >= a.cfg.VegetationMaxTemp {
-		srcT = firstTransition(rng, tiles.Sand, tiles.Rock, tiles.Dirt)
-	} else if me.Data.Temperature() <= a.cfg.VegetationMinTemp {
-		srcT = firstTransition(rng, tiles.Dirt, tiles.Rock)
-	} else if me.Data.Height() >= a.cfg.MountainLevel {
-		srcT = firstTransition(rng, tiles.Rock, tiles.Dirt)
+	src := firstFull(rng, tiles.tilesets(full.Full)...)
+	srcT := ""
+	if trans := a.chooseBiome(me, shoreFn, true); trans != nil {
+		srcT = firstTransition(rng, tiles.tilesets(trans.Transition)...)
 	}
 
 	ret := []*Event{newEvent(me.X, me.Y, a.cfg.ZOffsetLand, src, propertiesLand)}
@@ -292,7 +279,7 @@ func (a *Autotiler) placeLand(o Outline, rng *rand.Rand, me *area, tagsonly bool
 		ret = append(ret, newEvent(me.X, me.Y, a.cfg.ZOffsetLand+1, srcT, propertiesLand))
 	}
 
-	return ret, tag, nil
+	return ret, full.tag(), nil
 }
 
 func (a *Autotiler) placeWater(o Outline, rng *rand.Rand, me *area, tagonly bool) ([]*Event, string, error) {
@@ -304,25 +291,258 @@ func (a *Autotiler) placeWater(o Outline, rng *rand.Rand, me *area, tagonly bool
 	if tiles == nil || tiles.Water == nil {
 		return nil, "", nil
 	}
+
+	frozen := a.isFrozen(me.Data)
+	depth := a.cfg.SeaLevel > 0 || a.cfg.DeepWaterDistance > 0
+	deep := depth && a.isDeep(o, me)
+
+	tag := Water
+	if frozen {
+		tag = Ice
+	} else if deep {
+		tag = DeepWater
+	} else if depth {
+		tag = ShallowWater
+	}
+	crd := cardinals(o, me.X, me.Y)
+	steam := tiles.Steam != nil && borders(crd, isLava)
+	if steam {
+		tag = Steam
+	}
+	if a.isDock(o, me.Data, me.X, me.Y) {
+		tag = Dock
+	} else if bridgeFor(me.Data, tiles) != nil {
+		tag = "" // there's a bridge over us, see placeRoad
+	}
 	if tagonly {
-		return nil, Water, nil
+		return nil, tag, nil
+	}
+
+	var evts []*Event
+	if frozen {
+		evts = []*Event{a.placeIce(rng, tiles, crd)}
+	} else if deep && tiles.DeepWater != nil {
+		src := tiles.DeepWater.choosePiece(rng, crd, func(in *area) bool { return a.isDeep(o, in) })
+		evts = []*Event{newEvent(me.X, me.Y, a.cfg.ZOffsetWater, src, propertiesDeepWater)}
+	} else {
+		water := tiles.Water
+		if depth && tiles.ShallowWater != nil {
+			water = tiles.ShallowWater
+		}
+		src := water.choosePiece(rng, crd, func(in *area) bool { return in.Data.IsWater() })
+		evts = []*Event{newEvent(me.X, me.Y, a.cfg.ZOffsetWater, src, propertiesWater)}
+	}
+	if steam {
+		evts = append(evts, newColEvent(me.X, me.Y, a.cfg.ZOffsetWater, collisionLavaWater))
+	}
+
+	return evts, tag, nil
+}
+
+// rampRect returns the rectangle `r` made long enough
+// to span the height difference between it's ends, by extending it towards `down`.
+// Returns false if the ramp would need to be longer than we look for collisions
+// outside of a region, as it wouldn't match up across regions.
+func (a *Autotiler) rampRect(o Outline, r image.Rectangle, down Heading) (image.Rectangle, bool) {
+	if a.cfg.RampRise <= 0 {
+		return r, true
+	}
+
+	var high, low LandData
+	var length int
+	switch down {
+	case North, South:
+		high, low = o.LandAt(r.Min.X, r.Min.Y-1), o.LandAt(r.Min.X, r.Max.Y)
+		length = r.Dy()
+	default:
+		high, low = o.LandAt(r.Min.X-1, r.Min.Y), o.LandAt(r.Max.X, r.Min.Y)
+		length = r.Dx()
+	}
+
+	diff := high.Height() - low.Height()
+	if diff < 0 {
+		diff = -diff
+	}
+	need := (diff + a.cfg.RampRise - 1) / a.cfg.RampRise
+	if need <= length {
+		return r, true
+	}
+
+	extra := need - length
+	if extra > a.collisionMargin() {
+		return r, false
+	}
+	switch down {
+	case North:
+		r.Min.Y -= extra
+	case South:
+		r.Max.Y += extra
+	case West:
+		r.Min.X -= extra
+	case East:
+		r.Max.X += extra
+	}
+	return r, true
+}
+
+// preferRamp returns if we'd rather place a ramp than stairs on the given land
+func (a *Autotiler) preferRamp(d LandData) bool {
+	if rp, ok := d.(RampPreference); ok {
+		return rp.PreferRamp()
+	}
+	return a.cfg.PreferRamps
+}
+
+// rampFor returns the ramp collision matching the given stairs, if we have
+// the tiles for it
+func rampFor(tiles *LandTiles, stairs collisionType) collisionType {
+	switch {
+	case stairs == collisionStairsNS && tiles.RampNorthSouth != nil:
+		return collisionRampNS
+	case stairs == collisionStairsSN && tiles.RampSouthNorth != nil:
+		return collisionRampSN
+	case stairs == collisionStairsEW && tiles.RampEastWest != nil:
+		return collisionRampEW
+	case stairs == collisionStairsWE && tiles.RampWestEast != nil:
+		return collisionRampWE
+	}
+	return stairs
+}
+
+// placeCliffTop marks (and places CliffTop tiles on) tiles that are at the top of
+// a cliff face; that is tiles next to a face at the same height that aren't a face
+// themselves.
+func (a *Autotiler) placeCliffTop(o Outline, rng *rand.Rand, me *area, tagonly bool) ([]*Event, string, error) {
+	if me.Data.IsWater() || me.Data.IsMolten() || me.Data.IsNull() {
+		return nil, "", nil
+	}
+	tiles := me.Data.Tiles()
+	if tiles == nil || tiles.Cliff == nil {
+		return nil, "", nil
+	}
+
+	// heights around us, so we can check if our neighbours are cliff faces
+	var hs [5][5]int
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			hs[dy+2][dx+2] = o.LandAt(me.X+dx, me.Y+dy).Height()
+		}
+	}
+	isFace := func(x, y int) bool {
+		h := hs[y+2][x+2]
+		if a.cfg.CliffStep <= 0 && h < a.cfg.CliffLevel {
+			return false
+		}
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if a.isCliffDrop(h, hs[y+dy+2][x+dx+2]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if isFace(0, 0) {
+		return nil, "", nil
+	}
+
+	// a face is at our top if it isn't a cliff above us
+	h := me.Data.Height()
+	isTop := func(x, y int) bool {
+		return !a.isCliffDrop(hs[y+2][x+2], h) && isFace(x, y)
+	}
+
+	top := false
+	for dy := -1; dy <= 1 && !top; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if (dx != 0 || dy != 0) && isTop(dx, dy) {
+				top = true
+				break
+			}
+		}
+	}
+	if !top {
+		return nil, "", nil
+	}
+	if tagonly || tiles.CliffTop == nil {
+		return nil, CliffEdge, nil
 	}
 
 	crd := cardinals(o, me.X, me.Y)
-	src := tiles.Water.choosePiece(rng, crd, func(a *area) bool { return a.Data.IsWater() })
+	src := tiles.CliffTop.choosePiece(rng, crd, func(in *area) bool { return !isTop(in.X-me.X, in.Y-me.Y) })
+	if src == "" {
+		return nil, CliffEdge, nil
+	}
 
-	evts := []*Event{
-		newEvent(me.X, me.Y, a.cfg.ZOffsetWater, src, propertiesWater),
+	return []*Event{newEvent(me.X, me.Y, a.cfg.ZOffsetCliff, src, propertiesCliffTop)}, CliffEdge, nil
+}
+
+// isCliffDrop returns if there should be a cliff between land at height `h` and
+// an adjacent tile at height `n`
+func (a *Autotiler) isCliffDrop(h, n int) bool {
+	if h-n <= a.cfg.CliffMinDelta {
+		return false // not steep enough (or not lower at all)
 	}
+	if a.cfg.CliffStep <= 0 {
+		return true
+	}
+	return terrace(n, a.cfg.CliffStep) < terrace(h, a.cfg.CliffStep)
+}
 
-	if tiles.Bridge == nil || !me.Data.IsRoad() {
-		return evts, Water, nil
+// terrace returns which terrace (of the given height) height `h` is on
+func terrace(h, step int) int {
+	if h < 0 {
+		return (h - step + 1) / step
 	}
+	return h / step
+}
 
-	bsrc := tiles.Bridge.choosePiece(rng, crd, func(a *area) bool { return a.Data.IsRoad() && a.Data.IsWater() })
-	evts = append(evts, newEvent(me.X, me.Y, a.cfg.ZOffsetRoad, bsrc, propertiesRoad))
+// isDeep returns if the given water tile is deep, either because it's below
+// sea level or far enough from land
+func (a *Autotiler) isDeep(o Outline, me *area) bool {
+	if !me.Data.IsWater() {
+		return false
+	}
+	if a.cfg.SeaLevel > 0 && me.Data.Height() < a.cfg.SeaLevel {
+		return true
+	}
+	far := a.cfg.DeepWaterDistance
+	return far > 0 && landDistance(o, me.X, me.Y, far) >= far
+}
 
-	return evts, Road, nil
+// isFrozen returns if the given water is frozen (and we have ice to place)
+func (a *Autotiler) isFrozen(d LandData) bool {
+	if !d.IsWater() || d.Temperature() > a.cfg.SnowLevel {
+		return false
+	}
+	tiles := d.Tiles()
+	return tiles != nil && tiles.Ice != nil
+}
+
+// placeIce returns the ice tile for a frozen water tile, if it borders open
+// water we use a FrozenEdge piece (where we have them)
+func (a *Autotiler) placeIce(rng *rand.Rand, tiles *LandTiles, crd *nearby) *Event {
+	me := crd.Centre
+	isOpen := func(in *area) bool {
+		return in.Data.IsWater() && !a.isFrozen(in.Data)
+	}
+
+	thawing := false
+	for _, n := range crd.all() {
+		if isOpen(n) {
+			thawing = true
+			break
+		}
+	}
+
+	var src string
+	if thawing && tiles.FrozenEdge != nil {
+		src = tiles.FrozenEdge.choosePiece(rng, crd, func(in *area) bool { return !isOpen(in) })
+	} else {
+		src = tiles.Ice.choosePiece(rng, crd, func(in *area) bool { return a.isFrozen(in.Data) })
+	}
+
+	return newEvent(me.X, me.Y, a.cfg.ZOffsetWater, src, propertiesIce)
 }
 
 func (a *Autotiler) placeRoad(o Outline, rng *rand.Rand, me *area, tagonly bool) ([]*Event, string, error) {
@@ -331,8 +551,8 @@ func (a *Autotiler) placeRoad(o Outline, rng *rand.Rand, me *area, tagonly bool)
 	}
 	tiles := me.Data.Tiles()
 	ts := tiles.Road
-	if me.Data.IsWater() {
-		ts = tiles.Bridge
+	if impassable(me.Data) {
+		ts = bridgeFor(me.Data, tiles)
 	}
 	if ts == nil {
 		return nil, "", nil
@@ -357,22 +577,98 @@ func (a *Autotiler) placeMolten(o Outline, rng *rand.Rand, me *area, tagonly boo
 	if tiles.Lava == nil {
 		return nil, "", nil
 	}
-	if tagonly {
-		return nil, Lava, nil
-	}
 
 	crd := cardinals(o, me.X, me.Y)
+	crust := tiles.Obsidian != nil && borders(crd, isWater)
 
-	src := tiles.Lava.choosePiece(rng, crd, func(a *area) bool { return a.Data.IsMolten() && !a.Data.IsWater() })
-	return []*Event{
+	tag := Lava
+	if crust {
+		tag = Obsidian
+	}
+	if bridgeFor(me.Data, tiles) != nil {
+		tag = "" // there's a bridge over us, see placeRoad
+	}
+	if tagonly {
+		return nil, tag, nil
+	}
+
+	src := tiles.Lava.choosePiece(rng, crd, func(in *area) bool { return isLava(in.Data) })
+	evts := []*Event{
 		newEvent(me.X, me.Y, a.cfg.ZOffsetWater, src, propertiesLava),
-	}, Lava, nil
+	}
+	if crust {
+		evts = append(evts, newColEvent(me.X, me.Y, a.cfg.ZOffsetWater, collisionLavaWater))
+	}
+
+	return evts, tag, nil
+}
+
+// impassable returns if the land can't be walked on (so roads need bridges)
+func impassable(d LandData) bool {
+	return d.IsWater() || d.IsMolten() || d.IsNull()
+}
+
+// bridgeFor returns the Tileset to bridge a road over the given land, if the land
+// is impassable & we have a suitable bridge.
+func bridgeFor(d LandData, tiles *LandTiles) *Tileset {
+	if tiles == nil || !d.IsRoad() || !impassable(d) {
+		return nil
+	}
+	if isLava(d) && tiles.LavaBridge != nil {
+		return tiles.LavaBridge
+	}
+	return tiles.Bridge
+}
+
+// isLava returns if the land is molten (and not water)
+func isLava(d LandData) bool {
+	return d.IsMolten() && !d.IsWater()
+}
+
+// borders returns if any of the tiles around us match `fn`
+func borders(crd *nearby, fn func(LandData) bool) bool {
+	for _, n := range crd.all() {
+		if fn(n.Data) {
+			return true
+		}
+	}
+	return false
+}
+
+// lavaWater places obsidian & steam where lava meets water
+func (a *Autotiler) lavaWater(o Outline, tiles *LandTiles, col *collision) []*Event {
+	evts := []*Event{}
+	for _, e := range col.Events() {
+		rng := tileRand(a.cfg.Seed, e.X, e.Y, string(col.typ))
+		crd := cardinals(o, e.X, e.Y)
 
+		if crd.Centre.Data.IsWater() {
+			if tiles.Steam == nil {
+				continue
+			}
+			src := tiles.Steam.choosePiece(rng, crd, func(in *area) bool {
+				return in.Data.IsWater() && borders(cardinals(o, in.X, in.Y), isLava)
+			})
+			if src != "" {
+				evts = append(evts, newEvent(e.X, e.Y, a.cfg.ZOffsetSteam, src, propertiesSteam))
+			}
+			continue
+		}
+
+		if tiles.Obsidian == nil {
+			continue
+		}
+		src := tiles.Obsidian.choosePiece(rng, crd, func(in *area) bool { return isLava(in.Data) })
+		if src != "" {
+			evts = append(evts, newEvent(e.X, e.Y, a.cfg.ZOffsetWater, src, propertiesObsidian))
+		}
+	}
+	return evts
 }
 
 func (a *Autotiler) placeCliffs(o Outline, rng *rand.Rand, me *area, tagonly bool) ([]*Event, string, error) {
 	h := me.Data.Height()
-	if h < a.cfg.CliffLevel {
+	if a.cfg.CliffStep <= 0 && h < a.cfg.CliffLevel {
 		return nil, "", nil
 	}
 	tiles := me.Data.Tiles()
@@ -381,7 +677,7 @@ func (a *Autotiler) placeCliffs(o Outline, rng *rand.Rand, me *area, tagonly boo
 	}
 
 	crd := cardinals(o, me.X, me.Y)
-	src := tiles.Cliff.choosePiece(rng, crd, func(a *area) bool { return a.Data.Height() >= h })
+	src := tiles.Cliff.choosePiece(rng, crd, func(in *area) bool { return !a.isCliffDrop(h, in.Data.Height()) })
 	if src == "" {
 		return nil, "", nil
 	}
@@ -434,6 +730,9 @@ func (a *Autotiler) placeCliffs(o Outline, rng *rand.Rand, me *area, tagonly boo
 			stype = collisionStairsWE
 		}
 
+		if stype != "" && a.preferRamp(me.Data) {
+			stype = rampFor(tiles, stype)
+		}
 		if stype != "" {
 			evts = append(evts, newColEvent(me.X, me.Y, a.cfg.ZOffsetCliff, stype))
 		}
@@ -446,15 +745,19 @@ func (a *Autotiler) placeCliffs(o Outline, rng *rand.Rand, me *area, tagonly boo
 // indicating the kind of tile(s) that will be placed there.
 // We return user set tags + the tag we consider the most important for the terrain.
 func (a *Autotiler) TagsAt(o Outline, x, y int) ([]string, error) {
+	return a.tagsAt(a.prefetch(o, image.Rect(x, y, x+1, y+1)), x, y)
+}
+
+// tagsAt is TagsAt without prefetching
+func (a *Autotiler) tagsAt(o Outline, x, y int) ([]string, error) {
 	me := newArea(o, x, y)
-	placementFuncs := []fnPlacements{a.placeNull, a.placeCliffs, a.placeWater, a.placeMolten, a.placeRoad, a.placeLand}
-	rng := rand.New(rand.NewSource(0)) // doesn't affect the tag we get (only the specific src image)
+	site := newSite(o, a.cfg, me)
 
 	var err error
 	var tag string
-	for _, pfn := range placementFuncs {
+	for _, p := range a.tagPasses() {
 		// passing true here to skip logic that doesn't change the final tag
-		_, tag, err = pfn(o, rng, me, true)
+		_, tag, err = p.placer.Place(tileRand(a.cfg.Seed, x, y, p.name), site, true)
 		if err != nil {
 			return nil, err
 		}
@@ -469,6 +772,9 @@ func (a *Autotiler) TagsAt(o Outline, x, y int) ([]string, error) {
 	} else {
 		tags = append(tags, tag)
 	}
+	if parent, ok := parentTags[tag]; ok {
+		tags = append(tags, parent)
+	}
 
 	return tags, nil
 }
@@ -476,8 +782,30 @@ func (a *Autotiler) TagsAt(o Outline, x, y int) ([]string, error) {
 // SetObjects places objects from the given ObjectBin on to the map `t` within the area defined by
 // the region.
 func (a *Autotiler) SetObjects(o Outline, region image.Rectangle, t tile.Tileable, bin ObjectBin) error {
+	return a.SetObjectsContext(context.Background(), o, region, t, bin, nil)
+}
+
+// SetObjectsContext is SetObjects but returns early with the context error if `ctx` is
+// cancelled. If `progress` is given it's called as rows of the region are completed.
+func (a *Autotiler) SetObjectsContext(ctx context.Context, o Outline, region image.Rectangle, t tile.Tileable, bin ObjectBin, progress ProgressFunc) error {
+	r := a.newRun(region)
+	defer a.finish(r)
+
+	return a.setObjects(ctx, t, bin, r, progress)
+}
+
+// setObjects places objects for the given run
+func (a *Autotiler) setObjects(ctx context.Context, t tile.Tileable, bin ObjectBin, r *run, progress ProgressFunc) error {
+	region := r.region
+	total := region.Dx() * region.Dy()
+	done := 0
+
 	for ty := region.Min.Y; ty < region.Max.Y; ty++ {
 		for tx := region.Min.X; tx < region.Max.X; tx++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			// choose an object
 			id, obj, err := bin.Choose(t, tx, ty, a.cfg.ZOffsetObject)
 			if err != nil {
@@ -494,7 +822,18 @@ func (a *Autotiler) SetObjects(o Outline, region image.Rectangle, t tile.Tileabl
 			}
 
 			// and make an event
-			a.emitEvent(newObjEvent(tx, ty, a.cfg.ZOffsetObject, id))
+			e := newObjEvent(tx, ty, a.cfg.ZOffsetObject, id)
+			if r.plan != nil {
+				e.object = obj
+				r.plan.Events = append(r.plan.Events, e)
+				continue
+			}
+			a.emit(r, e)
+		}
+
+		done += region.Dx()
+		if progress != nil {
+			progress(done, total)
 		}
 	}
 	return nil
@@ -0,0 +1,96 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestSetLandRegionsMatch(t *testing.T) {
+	whole := image.Rect(0, 0, 80, 80)
+
+	// regions split at odd places, so features are cut in all sorts of ways
+	splits := map[string][]image.Rectangle{
+		"quarters": {
+			image.Rect(0, 0, 37, 41), image.Rect(37, 0, 80, 41),
+			image.Rect(0, 41, 37, 80), image.Rect(37, 41, 80, 80),
+		},
+		"strips": {
+			image.Rect(0, 0, 80, 13), image.Rect(0, 13, 80, 14), image.Rect(0, 14, 80, 52),
+			image.Rect(0, 52, 80, 80),
+		},
+		"columns": {
+			image.Rect(0, 0, 1, 80), image.Rect(1, 0, 29, 80), image.Rect(29, 0, 80, 80),
+		},
+	}
+
+	for _, ramps := range []bool{true, false} {
+		cfg := testConfig()
+		cfg.PreferRamps = ramps
+		o := newTestOutline()
+
+		want := newTestMap(whole.Dx(), whole.Dy())
+		err := newTestAutotiler(t, cfg).SetLand(o, whole, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, regions := range splits {
+			got := newTestMap(whole.Dx(), whole.Dy())
+			a := newTestAutotiler(t, cfg)
+			for _, r := range regions {
+				err := a.SetLand(o, r, got)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Run(fmt.Sprintf("%s-ramps-%v", name, ramps), func(t *testing.T) {
+				assertSameTiles(t, cfg, want, got, whole)
+			})
+		}
+	}
+}
+
+// stepOutline is a lake running over a cliff at x = 40
+type stepOutline struct{}
+
+func (stepOutline) LandAt(x, y int) LandData {
+	h := 200
+	if x >= 40 {
+		h = 140
+	}
+	return &testLand{height: h, temp: 15, rain: 100, water: y >= 2 && y < 22}
+}
+
+func TestSetLandRegionsMatchLongFeature(t *testing.T) {
+	// the waterfall is far longer than we look for collisions outside of a region
+	whole := image.Rect(0, 0, 80, 24)
+	cfg := testConfig()
+
+	want := newTestMap(whole.Dx(), whole.Dy())
+	err := newTestAutotiler(t, cfg).SetLand(stepOutline{}, whole, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	falls := 0
+	for _, s := range dumpMap(t, cfg, want, whole) {
+		if strings.Contains(s, "Waterfall") {
+			falls++
+		}
+	}
+	if falls < 20 {
+		t.Fatalf("expected a waterfall down the cliff, found %d tiles", falls)
+	}
+
+	got := newTestMap(whole.Dx(), whole.Dy())
+	a := newTestAutotiler(t, cfg)
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 80, 7), image.Rect(0, 7, 80, 15), image.Rect(0, 15, 80, 24)} {
+		err := a.SetLand(stepOutline{}, r, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertSameTiles(t, cfg, want, got, whole)
+}
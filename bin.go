@@ -213,10 +213,12 @@ func (o *Bin) Choose(t tile.Tileable, x, y, z int) (string, *tile.Map, error) {
 			// check that the base (bottom layer) of object sits on tiles
 			// with matching tags.
 			objheight := BaseHeight(obj)
+			base := image.Rect(x, y+obj.Height-objheight, x+obj.Width, y+obj.Height)
+			outline := o.tiler.prefetch(o.mapoutline, base)
 			suitable := true
-			for ty := y + obj.Height - objheight; ty < y+obj.Height; ty++ {
-				for tx := x; tx < x+obj.Width; tx++ {
-					tiletags, err := o.tiler.TagsAt(o.mapoutline, tx, ty)
+			for ty := base.Min.Y; ty < base.Max.Y; ty++ {
+				for tx := base.Min.X; tx < base.Max.X; tx++ {
+					tiletags, err := o.tiler.tagsAt(outline, tx, ty)
 					if err != nil {
 						return "", nil, err
 					}
@@ -0,0 +1,269 @@
+package autotile
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is an inclusive range of values (height, temperature etc)
+type Range struct {
+	Min int
+	Max int
+}
+
+// AtLeast returns a Range of all values >= v
+func AtLeast(v int) *Range {
+	return &Range{Min: v, Max: math.MaxInt32}
+}
+
+// AtMost returns a Range of all values <= v
+func AtMost(v int) *Range {
+	return &Range{Min: math.MinInt32, Max: v}
+}
+
+// Between returns a Range of all values from min to max (inclusive)
+func Between(min, max int) *Range {
+	return &Range{Min: min, Max: max}
+}
+
+// contains returns if `v` is within the range, ignoring `width` units at either end.
+// A nil range contains everything.
+func (r *Range) contains(v, width int) bool {
+	if r == nil {
+		return true
+	}
+	return v >= r.Min+width && v <= r.Max-width
+}
+
+// openEnded returns if the range has no upper limit (see AtLeast)
+func (r *Range) openEnded() bool {
+	return r.Max >= math.MaxInt32
+}
+
+// Biome describes some kind of ground & when it's placed.
+// A tile is in a biome if it's LandData falls within all of the given ranges,
+// where a nil range matches anything.
+//
+// Full tiles are placed where a tile is within each climate range (height,
+// temperature, rainfall) by at least Config.TransitionWidth, transitions are placed
+// over the whole range (ie. the outer TransitionWidth of each side blends into
+// whatever is next door).
+// Shore is measured in tiles (from water) & is never narrowed, transitions for
+// Shore are placed on the tiles just beyond Shore.Max (if it has one).
+type Biome struct {
+	// Name of the biome, eg. "tundra"
+	Name string
+
+	// Tag returned by TagsAt for tiles in this biome, if not set we use the Name
+	Tag string
+
+	// Height of the land
+	Height *Range
+
+	// Temperature of the land
+	Temperature *Range
+
+	// Rainfall of the land
+	Rainfall *Range
+
+	// Shore is the distance in tiles to the nearest water
+	Shore *Range
+
+	// NoBlend places full tiles over the whole of each range, rather than leaving
+	// TransitionWidth for transitions.
+	NoBlend bool
+
+	// Full is the names of the LandTiles Tilesets (eg. "Snow", "Dirt") to choose
+	// full tiles from, in order of preference.
+	// We use the first Tileset that is set with at least one Full tile.
+	Full []string
+
+	// Transition is the names of the LandTiles Tilesets to choose transition
+	// tiles from, in order of preference.
+	Transition []string
+}
+
+// validate checks the biome is sensible
+func (b *Biome) validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("%s: biome name is required", ErrMissingRequiredValue)
+	}
+	for _, name := range append(append([]string{}, b.Full...), b.Transition...) {
+		if !isTilesetName(name) {
+			return fmt.Errorf("%s: biome %s has unknown tileset %s", ErrInvalidValue, b.Name, name)
+		}
+	}
+	return nil
+}
+
+// tag returns the tag for tiles in this biome
+func (b *Biome) tag() string {
+	if b.Tag == "" {
+		return b.Name
+	}
+	return b.Tag
+}
+
+// DefaultBiomes returns the built in biome table for the given config.
+// Biomes are checked in order & the first match is used, so users can extend this
+// by adding their own biomes to the front (or before the final "grass" catch all).
+func DefaultBiomes(c *Config) []*Biome {
+	tsn := c.TransitionWidth
+	shore := AtMost(c.CliffLevel - 1) // we don't place beaches up high
+
+	table := []*Biome{
+		{
+			Name:        Snow,
+			Temperature: AtMost(c.SnowLevel),
+			Full:        []string{"Snow", "Dirt", "Rock"},
+			Transition:  []string{"Snow", "Dirt", "Rock"},
+		},
+	}
+
+	if c.BeachWidth > 0 {
+		wet := c.WetSandWidth
+		if wet > c.BeachWidth {
+			wet = c.BeachWidth
+		}
+		if wet > 0 {
+			// wet sand transitions into sand, or into whatever is beyond the beach
+			transition := []string{"WetSand"}
+			if wet == c.BeachWidth {
+				transition = []string{"Sand", "Dirt"}
+			}
+			table = append(table, &Biome{
+				Name:       "wet-sand",
+				Tag:        Sand,
+				Height:     shore,
+				Shore:      AtMost(wet),
+				NoBlend:    true,
+				Full:       []string{"WetSand", "Sand", "Rock"},
+				Transition: transition,
+			})
+		}
+		if wet < c.BeachWidth {
+			// without wet sand the beach runs under the water, as with wet sand
+			beach := AtMost(c.BeachWidth)
+			if wet > 0 {
+				beach = Between(wet+1, c.BeachWidth)
+			}
+			table = append(table, &Biome{
+				Name:       "beach",
+				Tag:        Sand,
+				Height:     shore,
+				Shore:      beach,
+				NoBlend:    true,
+				Full:       []string{"Sand", "Rock"},
+				Transition: []string{"Sand", "Dirt"},
+			})
+		}
+	}
+
+	table = append(
+		table,
+		&Biome{
+			Name:       "mountain",
+			Tag:        Rock,
+			Height:     AtLeast(c.MountainLevel),
+			Full:       []string{"Rock", "Dirt"},
+			Transition: []string{"Rock", "Dirt"},
+		},
+		&Biome{
+			Name:        "cold",
+			Tag:         Dirt,
+			Temperature: AtMost(c.VegetationMinTemp),
+			Full:        []string{"Dirt", "Rock"},
+			Transition:  []string{"Dirt", "Rock"},
+		},
+		&Biome{
+			Name:        "desert",
+			Tag:         Sand,
+			Temperature: AtLeast(c.VegetationMaxTemp),
+			Full:        []string{"Sand", "Rock", "Dirt"},
+			Transition:  []string{"Sand", "Rock", "Dirt"},
+		},
+	)
+
+	if c.SwampMinRainfall > 0 {
+		table = append(table, &Biome{
+			Name:       Swamp,
+			Rainfall:   AtLeast(c.SwampMinRainfall),
+			Full:       []string{"Swamp", "Mud", "Grass"},
+			Transition: []string{"Swamp", "Mud"},
+		})
+		if tsn > 0 {
+			table = append(table, &Biome{
+				Name:     Mud,
+				Rainfall: Between(c.SwampMinRainfall, c.SwampMinRainfall+tsn-1),
+				NoBlend:  true,
+				Full:     []string{"Mud", "Grass"},
+			})
+		}
+	}
+	if c.AridMaxRainfall > 0 {
+		table = append(table, &Biome{
+			Name:       DryGrass,
+			Rainfall:   AtMost(c.AridMaxRainfall),
+			Full:       []string{"DryGrass", "Grass"},
+			Transition: []string{"DryGrass"},
+		})
+	}
+
+	return append(table, &Biome{
+		Name: Grass,
+		Full: []string{"Grass", "Dirt", "Rock"},
+	})
+}
+
+// maxShore returns the furthest distance from water any biome needs to know about.
+// For open ended ranges (eg. AtLeast(n)) we only need to know we're past the Min.
+func (a *Autotiler) maxShore() int {
+	max := 0
+	for _, b := range a.cfg.BiomeTable {
+		if b.Shore == nil {
+			continue
+		}
+		d := b.Shore.Max
+		if b.Shore.openEnded() {
+			d = b.Shore.Min
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// chooseBiome returns the first biome (if any) that `me` is in, if `transition` is
+// set we're looking for the biome whose transitions should be placed at `me`.
+// `shore` is called (at most once) if we need the distance to water.
+func (a *Autotiler) chooseBiome(me *area, shore func() int, transition bool) *Biome {
+	width := a.cfg.TransitionWidth
+	if transition {
+		width = 0
+	}
+
+	for _, b := range a.cfg.BiomeTable {
+		w := width
+		if b.NoBlend {
+			w = 0
+		}
+		if !b.Height.contains(me.Data.Height(), w) ||
+			!b.Temperature.contains(me.Data.Temperature(), w) ||
+			!b.Rainfall.contains(me.Data.Rainfall(), w) {
+			continue
+		}
+		if b.Shore == nil {
+			return b
+		}
+
+		d := shore()
+		if transition && !b.Shore.openEnded() && d == b.Shore.Max+1 {
+			return b
+		} else if !transition && b.Shore.contains(d, 0) {
+			return b
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package autotile
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shoreOutline is flat land with water to the West of x = 2
+type shoreOutline struct{}
+
+func (shoreOutline) LandAt(x, y int) LandData {
+	return &testLand{height: 50, temp: 15, rain: 100, water: x < 2}
+}
+
+func TestDefaultBiomesWetSand(t *testing.T) {
+	cases := []struct {
+		wet   int
+		names []string
+	}{
+		{0, []string{"beach"}},
+		{1, []string{"wet-sand", "beach"}},
+		{2, []string{"wet-sand"}},
+		{5, []string{"wet-sand"}},
+	}
+
+	for _, c := range cases {
+		cfg := testConfig()
+		cfg.WetSandWidth = c.wet
+
+		names := []string{}
+		for _, b := range DefaultBiomes(cfg) {
+			if b.Shore != nil {
+				names = append(names, b.Name)
+			}
+		}
+		if !reflect.DeepEqual(names, c.names) {
+			t.Errorf("wet sand %d: expected shore biomes %v got %v", c.wet, c.names, names)
+		}
+	}
+}
+
+func TestBiomeShoreTiles(t *testing.T) {
+	cases := []struct {
+		wet  int
+		want []string
+	}{
+		// no wet sand, sand runs under the water
+		{0, []string{
+			"(0,1,0) Sand.Full", "(0,1,2) Water.Full", "(1,1,0) Sand.Full", "(1,1,2) Water.WestHalf",
+			"(2,1,0) Sand.Full", "(3,1,0) Sand.Full", "(4,1,0) Grass.Full", "(4,1,1) Sand.Transition",
+		}},
+		// wet sand transitions into sand, which transitions into grass
+		{1, []string{
+			"(0,1,0) WetSand.Full", "(0,1,2) Water.Full", "(1,1,0) WetSand.Full", "(1,1,2) Water.WestHalf",
+			"(2,1,0) WetSand.Full", "(3,1,0) Sand.Full", "(3,1,1) WetSand.Transition", "(4,1,0) Grass.Full",
+			"(4,1,1) Sand.Transition",
+		}},
+		// the beach is all wet sand
+		{2, []string{
+			"(0,1,0) WetSand.Full", "(0,1,2) Water.Full", "(1,1,0) WetSand.Full", "(1,1,2) Water.WestHalf",
+			"(2,1,0) WetSand.Full", "(3,1,0) WetSand.Full", "(4,1,0) Grass.Full", "(4,1,1) Sand.Transition",
+		}},
+	}
+
+	for _, c := range cases {
+		cfg := testConfig()
+		cfg.WetSandWidth = c.wet
+		a := newTestAutotiler(t, cfg)
+
+		m := newTestMap(10, 3)
+		err := a.SetLand(shoreOutline{}, image.Rect(0, 0, 10, 3), m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := dumpMap(t, cfg, m, image.Rect(0, 1, 5, 2))
+		for i, s := range got {
+			got[i] = s[:strings.LastIndex(s, ".")] // ignore which variant was chosen
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("wet sand %d: expected\n%v\ngot\n%v", c.wet, c.want, got)
+		}
+	}
+}
+
+func TestBiomeShoreAtLeast(t *testing.T) {
+	cfg := testConfig()
+	cfg.BiomeTable = append(
+		[]*Biome{{Name: "inland", Tag: Rock, Shore: AtLeast(5), Full: []string{"Rock"}}},
+		DefaultBiomes(cfg)...,
+	)
+	a := newTestAutotiler(t, cfg)
+
+	// an open ended range only needs to know we're at least it's Min from water
+	if max := a.maxShore(); max != 5 {
+		t.Errorf("expected max shore 5 got %d", max)
+	}
+
+	// x = 2 is one tile from water
+	want := []string{Water, Sand, Sand, Grass, Grass, Rock, Rock, Rock, Rock}
+	for x := 1; x < 10; x++ {
+		tags, err := a.TagsAt(shoreOutline{}, x, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tags[0] != want[x-1] {
+			t.Errorf("expected %s at x %d, got %v", want[x-1], x, tags)
+		}
+	}
+}
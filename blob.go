@@ -0,0 +1,58 @@
+package autotile
+
+import (
+	"math/rand"
+)
+
+// BlobMask returns the key into Tileset.Blob for a tile where the adjacent tiles
+// in the given directions are of the same terrain.
+//
+// Each direction is a bit (1 << Heading), so North is 1, NorthEast 2, East 4 and so on.
+// Corners only matter if both of the edges next to them are set (ie. NorthEast is only
+// counted if North & East are), which reduces the 256 possible masks to 47.
+func BlobMask(in ...Heading) int {
+	mask := 0
+	for _, h := range in {
+		mask |= 1 << uint(h)
+	}
+	return reduceBlobMask(mask)
+}
+
+// BlobMasks returns all 47 keys a Tileset.Blob can be asked for, in ascending order.
+func BlobMasks() []int {
+	seen := map[int]bool{}
+	masks := []int{}
+	for i := 0; i < 256; i++ {
+		m := reduceBlobMask(i)
+		if !seen[m] {
+			seen[m] = true
+			masks = append(masks, m)
+		}
+	}
+	return masks
+}
+
+// reduceBlobMask clears corner bits where either adjacent edge isn't set
+func reduceBlobMask(mask int) int {
+	corners := [][3]Heading{
+		{NorthEast, North, East},
+		{SouthEast, South, East},
+		{SouthWest, South, West},
+		{NorthWest, North, West},
+	}
+	for _, c := range corners {
+		if mask&(1<<uint(c[1])) == 0 || mask&(1<<uint(c[2])) == 0 {
+			mask &^= 1 << uint(c[0])
+		}
+	}
+	return mask
+}
+
+// blobPiece returns a piece from our Blob for the given neighbours (if we have one)
+func (t *Tileset) blobPiece(rng *rand.Rand, in []Heading) (string, bool) {
+	pieces, ok := t.Blob[BlobMask(in...)]
+	if !ok || len(pieces) == 0 {
+		return "", false
+	}
+	return t.Pick(rng, pieces), true
+}
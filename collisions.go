@@ -2,6 +2,16 @@ package autotile
 
 import (
 	"image"
+	"sort"
+)
+
+const (
+	// minCollisionMargin is the least distance (in tiles) outside of a region we
+	// look for collisions, see Autotiler.collisionMargin
+	minCollisionMargin = 8
+
+	// maxHeight is the highest LandData Height we expect
+	maxHeight = 255
 )
 
 // some kind of special tile intersection that needs extra care
@@ -17,6 +27,15 @@ var (
 	collisionStairsSN collisionType = "stairs-sn"
 	collisionStairsEW collisionType = "stairs-ew"
 	collisionStairsWE collisionType = "stairs-we"
+
+	collisionRampNS collisionType = "ramp-ns"
+	collisionRampSN collisionType = "ramp-sn"
+	collisionRampEW collisionType = "ramp-ew"
+	collisionRampWE collisionType = "ramp-we"
+
+	collisionLavaWater collisionType = "lava-water"
+
+	collisionDock collisionType = "dock"
 )
 
 func (t collisionType) isStairs() bool {
@@ -27,30 +46,107 @@ func (t collisionType) isStairs() bool {
 	return false
 }
 
-//
+// collisionMargin is how far (in tiles) outside of a region we look for collisions,
+// so that collisions crossing the edge of a region are sized the same from both sides.
+// This is at least as far as the largest feature whose size we know can reach.
+func (a *Autotiler) collisionMargin() int {
+	margin := minCollisionMargin
+	if a.cfg.RampRise > 0 {
+		// the longest ramp climbs from the bottom to the top of the height range
+		if r := (maxHeight + a.cfg.RampRise - 1) / a.cfg.RampRise; r > margin {
+			margin = r
+		}
+	}
+	if a.cfg.DockLength > margin {
+		margin = a.cfg.DockLength
+	}
+	return margin
+}
+
+// collisionHandler gathers collision events & groups them into collisions
 type collisionHandler struct {
-	cols []*collision
+	events []*Event
 }
 
+// All returns the collisions found so far. Touching events (including diagonally)
+// of the same type are one collision; collisions are grouped & ordered by location
+// (rather than the order events arrived in) so they come out the same no matter how
+// the map was split into regions.
 func (c *collisionHandler) All() []*collision {
-	return c.cols
+	byType := map[collisionType][]*Event{}
+	for _, e := range c.events {
+		byType[e.collisionType] = append(byType[e.collisionType], e)
+	}
+
+	cols := []*collision{}
+	for typ, evts := range byType {
+		sortEvents(evts)
+
+		at := map[image.Point]*Event{}
+		for _, e := range evts {
+			if _, ok := at[image.Pt(e.X, e.Y)]; !ok {
+				at[image.Pt(e.X, e.Y)] = e
+			}
+		}
+
+		seen := map[image.Point]bool{}
+		for _, e := range evts {
+			start := image.Pt(e.X, e.Y)
+			if seen[start] {
+				continue
+			}
+			seen[start] = true
+
+			col := &collision{typ: typ, minX: e.X, maxX: e.X, minY: e.Y, maxY: e.Y}
+			queue := []image.Point{start}
+			for len(queue) > 0 {
+				p := queue[0]
+				queue = queue[1:]
+				col.add(at[p])
+
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						n := image.Pt(p.X+dx, p.Y+dy)
+						if _, ok := at[n]; !ok || seen[n] {
+							continue
+						}
+						seen[n] = true
+						queue = append(queue, n)
+					}
+				}
+			}
+			sortEvents(col.events)
+
+			cols = append(cols, col)
+		}
+	}
+
+	sort.Slice(cols, func(i, j int) bool {
+		a, b := cols[i].events[0], cols[j].events[0]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return cols[i].typ < cols[j].typ
+	})
+
+	return cols
 }
 
 func (c *collisionHandler) append(e *Event) {
-	for _, col := range c.cols {
-		if col.accept(e) {
-			return
+	c.events = append(c.events, e)
+}
+
+// sortEvents sorts events by row, then column
+func sortEvents(evts []*Event) {
+	sort.SliceStable(evts, func(i, j int) bool {
+		if evts[i].Y != evts[j].Y {
+			return evts[i].Y < evts[j].Y
 		}
-	}
-	newCol := &collision{
-		typ:    e.collisionType,
-		events: []*Event{e},
-		maxX:   e.X,
-		minX:   e.X,
-		maxY:   e.Y,
-		minY:   e.Y,
-	}
-	c.cols = append(c.cols, newCol)
+		return evts[i].X < evts[j].X
+	})
 }
 
 type collision struct {
@@ -67,39 +163,35 @@ func (c *collision) Max() image.Rectangle {
 	return image.Rect(c.minX, c.minY, c.maxX, c.maxY)
 }
 
+// bounds returns the smallest rectangle containing all of our events (unlike Max,
+// the Max of the rectangle is exclusive).
+func (c *collision) bounds() image.Rectangle {
+	return image.Rect(c.minX, c.minY, c.maxX+1, c.maxY+1)
+}
+
 func (c *collision) Events() []*Event {
 	return c.events
 }
 
-func (c *collision) accept(in *Event) bool {
-	if c.typ != in.collisionType {
-		return false
+// add adds the event to this collision, growing it to fit
+func (c *collision) add(in *Event) {
+	if in.X < c.minX {
+		c.minX = in.X
 	}
-	for _, e := range c.events {
-		dx := e.X - in.X
-		dy := e.Y - in.Y
-		if (dx <= 1 && dx >= -1) && (dy <= 1 && dy >= -1) {
-			if in.X < c.minX {
-				c.minX = in.X
-			}
-			if in.X > c.maxX {
-				c.maxX = in.X
-			}
-			if in.Y < c.minY {
-				c.minY = in.Y
-			}
-			if in.Y > c.maxY {
-				c.maxY = in.Y
-			}
-			c.events = append(c.events, in)
-			return true
-		}
+	if in.X > c.maxX {
+		c.maxX = in.X
 	}
-	return false
+	if in.Y < c.minY {
+		c.minY = in.Y
+	}
+	if in.Y > c.maxY {
+		c.maxY = in.Y
+	}
+	c.events = append(c.events, in)
 }
 
 func newCollisionHandler() *collisionHandler {
 	return &collisionHandler{
-		cols: []*collision{},
+		events: []*Event{},
 	}
 }
@@ -17,10 +17,10 @@ var (
 // Config dictates various top level concerns with our rendering / creation process
 type Config struct {
 	// Seed is used for RNG. If zero a random value will be used.
-	// Each region will get it's own Seed based on this + some math operations
-	// on the min/max x/y values given (deterministically) .. this means that
-	// the same function call with the same input Outline for a given rectangle
-	// ("region") should choose the same tiles (assuming the Outline is returning
+	// Each tile (and each placement pass on that tile) gets it's own random numbers
+	// from a hash of (Seed, x, y, pass) .. this means that the same tile is chosen
+	// for a given (x,y) no matter how a map is split into regions, so maps set region
+	// by region match up at their borders (assuming the Outline is returning
 	// the same data each time).
 	Seed int64
 
@@ -44,6 +44,10 @@ type Config struct {
 	// Set to default value if not set. In general you shouldn't need to set this.
 	ZOffsetWaterfall int
 
+	// Layer at which steam (see LandTiles.Steam) is placed, over water & waterfalls
+	// Set to default value if not set. In general you shouldn't need to set this.
+	ZOffsetSteam int
+
 	// Layer at which objects are placed (ie, from an objectbin)
 	// Set to default value if not set. If you need to set this then you probably want
 	// it to be higher than all of the other offsets ..
@@ -54,6 +58,12 @@ type Config struct {
 	// Minimum of 0
 	BeachWidth int
 
+	// WetSandWidth is how many tiles of the beach (counting from the water) use
+	// wet sand (LandTiles.WetSand) rather than sand. Wet sand transitions into dry
+	// sand on the next tile out.
+	// Minimum of 0
+	WetSandWidth int
+
 	// TransitionWidth indicates how many `units` we take to transition from one
 	// ground type to another.
 	// Ie. we start using sand transitions at VegetationMaxTemp and move
@@ -71,15 +81,72 @@ type Config struct {
 	// Temp in degrees C
 	VegetationMinTemp int
 
+	// SwampMinRainfall is the rainfall at (or above) which land turns to swamp,
+	// with mud placed over the first TransitionWidth units.
+	// Zero (the default) means we don't place swamps.
+	SwampMinRainfall int
+
+	// AridMaxRainfall is the rainfall at (or below) which grass dries out.
+	// Ie. we start using dry grass transitions at AridMaxRainfall and move to
+	// full dry grass at AridMaxRainfall-TransitionWidth
+	// Zero (the default) means we don't place dry grass.
+	AridMaxRainfall int
+
+	// SeaLevel is the height below which water is considered deep (see LandTiles.DeepWater).
+	// Zero (the default) means we don't decide depth by height.
+	// Height value 0-255
+	SeaLevel int
+
+	// DeepWaterDistance is how many tiles from land water has to be before we consider
+	// it deep (see LandTiles.DeepWater).
+	// Zero (the default) means we don't decide depth by distance.
+	DeepWaterDistance int
+
+	// DockLength is how far (in tiles) docks extend out over water from the end
+	// of a road at the shore (see LandTiles.Dock). Docks stop early if they reach land.
+	// Zero (the default) means we don't place docks.
+	DockLength int
+
+	// BiomeTable decides which ground tiles are placed given the height, temperature,
+	// rainfall & distance to water of each tile. Biomes are checked in order &
+	// the first that matches is used (if none match no ground tile is placed).
+	// If not set we use DefaultBiomes (built from the values in this Config).
+	BiomeTable []*Biome
+
 	// Height above which we consider terrain mountainous (limited to no vegetation)
 	// and generally barren / rocky.
 	// Height value 0-255
 	MountainLevel int
 
 	// CliffLevel is the height at which we consider placing cliffs & waterfalls.
+	// Not used if CliffStep is set.
 	// Height value 0-255
 	CliffLevel int
 
+	// CliffStep splits heights into terraces CliffStep units high, if set we place
+	// cliffs at any height where the land drops from one terrace to a lower one.
+	// Zero (the default) means we place cliffs above CliffLevel only.
+	// Height value 0-255
+	CliffStep int
+
+	// CliffMinDelta is how much higher a tile must be than it's neighbour for there
+	// to be a cliff between them, so gentle slopes don't make cliffs.
+	// Height value 0-255
+	CliffMinDelta int
+
+	// PreferRamps places ramps (see LandTiles.RampNorthSouth etc) rather than
+	// stairs where roads cross cliffs. This can be decided per tile by LandData
+	// implementing RampPreference.
+	PreferRamps bool
+
+	// RampRise is how much height a ramp climbs per tile, ramps are made long
+	// enough to span the height of the cliff.
+	// Nb. we look for collisions (ramps, stairs ..) up to 255/RampRise tiles outside
+	// of a region so that long ramps match up across regions, so small values are slower.
+	// Zero (the default) means ramps are the same size as stairs would be.
+	// Height value 0-255
+	RampRise int
+
 	// Temperature in degrees below which we render snow / ice
 	// Temp in degrees C
 	SnowLevel int
@@ -94,11 +161,42 @@ func (c *Config) Validate() error {
 	if c.BeachWidth < 0 {
 		c.BeachWidth = 0
 	}
+	if c.WetSandWidth < 0 {
+		c.WetSandWidth = 0
+	}
+	if c.DeepWaterDistance < 0 {
+		c.DeepWaterDistance = 0
+	}
+	if c.DockLength < 0 {
+		c.DockLength = 0
+	}
+	if c.CliffStep < 0 {
+		c.CliffStep = 0
+	}
+	if c.CliffMinDelta < 0 {
+		c.CliffMinDelta = 0
+	}
+	if c.RampRise < 0 {
+		c.RampRise = 0
+	}
 	if c.TransitionWidth < 0 {
 		c.TransitionWidth = 0
 	}
 	if c.VegetationMaxTemp <= c.VegetationMinTemp {
-		return fmt.Errorf("%w: vegetation max temp should be greater than min temp", ErrInvalidValue)
+		return fmt.Errorf("%s: vegetation max temp should be greater than min temp", ErrInvalidValue)
+	}
+
+	if c.SwampMinRainfall > 0 && c.AridMaxRainfall >= c.SwampMinRainfall {
+		return fmt.Errorf("%s: swamp min rainfall should be greater than arid max rainfall", ErrInvalidValue)
+	}
+
+	if c.BiomeTable == nil {
+		c.BiomeTable = DefaultBiomes(c)
+	}
+	for _, b := range c.BiomeTable {
+		if err := b.validate(); err != nil {
+			return err
+		}
 	}
 
 	if c.ZOffsetLand < 0 {
@@ -116,6 +214,9 @@ func (c *Config) Validate() error {
 	if c.ZOffsetWaterfall <= 0 {
 		c.ZOffsetWaterfall = zoffsetWaterfall
 	}
+	if c.ZOffsetSteam <= 0 {
+		c.ZOffsetSteam = zoffsetSteam
+	}
 	if c.ZOffsetObject <= 0 {
 		c.ZOffsetObject = zoffsetObject
 	}
@@ -0,0 +1,85 @@
+package autotile
+
+import (
+	"math/rand"
+)
+
+// isDock returns if a dock is placed over the water at (x, y).
+// Docks extend out over water from the end of a road at the shore, for up to
+// Config.DockLength tiles or until they reach land.
+func (a *Autotiler) isDock(o Outline, d LandData, x, y int) bool {
+	if a.cfg.DockLength < 1 || !d.IsWater() || d.IsRoad() {
+		return false
+	}
+	tiles := d.Tiles()
+	if tiles == nil || tiles.Dock == nil {
+		return false
+	}
+
+	for _, h := range []Heading{North, East, South, West} {
+		// walk back toward the shore, looking for the road the dock extends
+		dx, dy := h.Offset()
+		for i := 1; i <= a.cfg.DockLength; i++ {
+			n := o.LandAt(x-dx*i, y-dy*i)
+			if !n.IsWater() {
+				if isRoadEnd(o, x-dx*i, y-dy*i, h) {
+					return true
+				}
+				break
+			}
+			if n.IsRoad() {
+				break // a bridge
+			}
+		}
+	}
+
+	return false
+}
+
+// isRoadEnd returns if (x, y) is the end of a road on land, where the road would
+// continue heading `h`.
+func isRoadEnd(o Outline, x, y int, h Heading) bool {
+	d := o.LandAt(x, y)
+	if !d.IsRoad() || impassable(d) {
+		return false
+	}
+
+	behind := Heading((h + 4) % 8)
+	found := false
+	for _, c := range []Heading{North, East, South, West} {
+		dx, dy := c.Offset()
+		if !o.LandAt(x+dx, y+dy).IsRoad() {
+			continue
+		}
+		if c != behind {
+			return false // the road turns or carries on
+		}
+		found = true
+	}
+
+	return found
+}
+
+// detectDock is the Feature.Detect func for docks
+func (a *Autotiler) detectDock(s *Site) bool {
+	return a.isDock(s.o, s.Data, s.X, s.Y)
+}
+
+// resolveDock is the Feature.Resolve func for docks, each tile is seeded by it's
+// own location so a dock is the same no matter which region it's placed in.
+func (a *Autotiler) resolveDock(_ *rand.Rand, m *FeatureMatch) ([]*Event, error) {
+	isIn := func(in *area) bool { return in.Data.IsRoad() || a.isDock(m.o, in.Data, in.X, in.Y) }
+
+	evts := []*Event{}
+	for _, c := range m.Cells {
+		tiles := m.o.LandAt(c.X, c.Y).Tiles()
+		if tiles == nil || tiles.Dock == nil {
+			continue
+		}
+		rng := tileRand(a.cfg.Seed, c.X, c.Y, m.Name)
+		src := tiles.Dock.choosePiece(rng, cardinals(m.o, c.X, c.Y), isIn)
+		evts = append(evts, newEvent(c.X, c.Y, a.cfg.ZOffsetRoad, src, propertiesDock))
+	}
+
+	return evts, nil
+}
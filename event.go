@@ -1,6 +1,10 @@
 package autotile
 
 import (
+	"image"
+	"sync"
+	"sync/atomic"
+
 	"github.com/voidshard/tile"
 )
 
@@ -8,11 +12,27 @@ import (
 // We report the location (x,y,z) and either of
 // - the id of the object placed
 // - the source (Src) & properties set
+//
+// Each call to SetLand / SetObjects (etc) is a 'run' with it's own Run ID, events
+// within a run are numbered from 1 (Seq). When a run finishes we send a final event
+// with Done set (this event places nothing).
 type Event struct {
 	X int
 	Y int
 	Z int
 
+	// Region given to the call that caused this event
+	Region image.Rectangle
+
+	// Run is the ID of the call that caused this event
+	Run uint64
+
+	// Seq is the order of this event within it's run
+	Seq uint64
+
+	// Done indicates the run has finished; no more events will be sent for it
+	Done bool
+
 	// if a tile is set
 	Src        string
 	Properties *tile.Properties
@@ -22,6 +42,12 @@ type Event struct {
 
 	// Collision
 	collisionType collisionType
+
+	// the object placed (if any), kept so that a Plan can be applied
+	object *tile.Map
+
+	// animation frames of the tile set (if any)
+	anim *animation
 }
 
 //
@@ -42,3 +68,169 @@ func newColEvent(x, y, z int, typ collisionType) *Event {
 func newObjEvent(x, y, z int, id string) *Event {
 	return &Event{X: x, Y: y, Z: z, ObjectID: id}
 }
+
+// run tracks the state of a single SetLand / SetObjects call
+type run struct {
+	id     uint64
+	region image.Rectangle
+	seq    uint64
+
+	// if set we're planning; events are added here rather than enacted
+	plan *Plan
+}
+
+// newRun starts a new run over the given region
+func (a *Autotiler) newRun(region image.Rectangle) *run {
+	return &run{id: atomic.AddUint64(&a.runs, 1), region: region}
+}
+
+// finish sends the final event for the given run
+func (a *Autotiler) finish(r *run) {
+	if r.plan != nil {
+		return // plans don't send events
+	}
+	r.seq++
+	a.emitEvent(&Event{Region: r.region, Run: r.id, Seq: r.seq, Done: true})
+}
+
+// emit stamps the event with the run information & sends it to subscribers
+func (a *Autotiler) emit(r *run, e *Event) {
+	r.seq++
+	e.Region = r.region
+	e.Run = r.id
+	e.Seq = r.seq
+	a.emitEvent(e)
+}
+
+// subscriber is a single listener for events
+type subscriber struct {
+	ch   chan *Event
+	done chan struct{}
+	once sync.Once
+
+	// held (read) while sending on ch, so ch isn't closed mid send
+	lock   sync.RWMutex
+	closed bool
+}
+
+// stop signals that the subscriber is going away
+func (s *subscriber) stop() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// close stops the subscriber & closes it's channel once any send in progress
+// has given up.
+func (s *subscriber) close() {
+	s.stop() // nb. unblocks send
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+}
+
+// send blocks until `e` is sent, the subscriber is stopped or `closing` is closed.
+// Returns false if `closing` was closed.
+func (s *subscriber) send(e *Event, closing <-chan struct{}) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return true
+	}
+	select {
+	case s.ch <- e:
+	case <-s.done:
+	case <-closing:
+		return false
+	}
+	return true
+}
+
+// Subscribe returns a channel on which events will be sent as the autotiler places
+// tiles & objects, and a func to cancel the subscription (which closes the channel).
+// Any number of subscribers may listen at once, each gets every event.
+//
+// Events are not stored, if you're not subscribed when the event occurs then you miss
+// out. Events are never dropped for a subscriber, so once it's buffer is full the
+// autotiler waits for it; subscribers must consume events or cancel.
+func (a *Autotiler) Subscribe(buffer int) (<-chan *Event, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	s := &subscriber{ch: make(chan *Event, buffer), done: make(chan struct{})}
+
+	a.subLock.Lock()
+	defer a.subLock.Unlock()
+
+	if a.closed {
+		close(s.ch)
+		return s.ch, func() {}
+	}
+	a.subs[s] = true
+
+	return s.ch, func() { a.unsubscribe(s) }
+}
+
+// unsubscribe removes & closes the given subscriber
+func (a *Autotiler) unsubscribe(s *subscriber) {
+	s.stop() // nb. unblocks emitEvent if it's waiting on this subscriber
+
+	a.subLock.Lock()
+	delete(a.subs, s)
+	a.subLock.Unlock()
+
+	s.close()
+}
+
+// Events allows a user to see what the autotiler is placing where
+// as the decisions are made.
+// If Events() is called the caller must consume events. All calls return the
+// same channel, which is closed by Close().
+//
+// Deprecated: use Subscribe
+func (a *Autotiler) Events() <-chan *Event {
+	a.eventsOnce.Do(func() {
+		a.events, _ = a.Subscribe(0)
+	})
+	return a.events
+}
+
+// Close closes all subscriber channels; no more events will be sent.
+func (a *Autotiler) Close() error {
+	a.closeOnce.Do(func() { close(a.closing) }) // nb. unblocks emitEvent
+
+	a.subLock.Lock()
+	subs := a.subs
+	a.closed = true
+	a.subs = map[*subscriber]bool{}
+	a.subLock.Unlock()
+
+	for s := range subs {
+		s.close()
+	}
+
+	return nil
+}
+
+// emitEvent sends the event to all current subscribers.
+// We don't hold subLock while sending, so subscribers can (un)subscribe from
+// their read loops.
+func (a *Autotiler) emitEvent(e *Event) {
+	a.subLock.RLock()
+	subs := make([]*subscriber, 0, len(a.subs))
+	for s := range a.subs {
+		subs = append(subs, s)
+	}
+	a.subLock.RUnlock()
+
+	for _, s := range subs {
+		if !s.send(e, a.closing) {
+			return
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package autotile
+
+import (
+	"image"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor fails the test if `done` isn't closed in good time (ie. we're deadlocked)
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// setLandAsync runs SetLand over a small map, closing the returned channel when done
+func setLandAsync(t *testing.T, a *Autotiler) (<-chan struct{}, *error) {
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		err = a.SetLand(newTestOutline(), image.Rect(0, 0, 12, 12), newTestMap(12, 12))
+	}()
+	return done, &err
+}
+
+func TestSubscribeEveryEvent(t *testing.T) {
+	a := newTestAutotiler(t, testConfig())
+	defer a.Close()
+
+	// subscribers with various buffers, each reads until the run is done
+	buffers := []int{0, 1, 1000}
+	got := make([][]*Event, len(buffers))
+	wg := sync.WaitGroup{}
+	for i, b := range buffers {
+		events, cancel := a.Subscribe(b)
+		defer cancel()
+		wg.Add(1)
+		go func(i int, events <-chan *Event) {
+			defer wg.Done()
+			for e := range events {
+				got[i] = append(got[i], e)
+				if e.Done {
+					return
+				}
+			}
+		}(i, events)
+	}
+
+	done, err := setLandAsync(t, a)
+	waitFor(t, done, "SetLand")
+	if *err != nil {
+		t.Fatal(*err)
+	}
+	finished := make(chan struct{})
+	go func() { wg.Wait(); close(finished) }()
+	waitFor(t, finished, "subscribers")
+
+	for i, evts := range got {
+		if len(evts) < 2 {
+			t.Fatalf("subscriber %d: expected events, got %d", i, len(evts))
+		}
+		if len(evts) != len(got[0]) {
+			t.Errorf("subscriber %d: expected %d events got %d", i, len(got[0]), len(evts))
+		}
+
+		run := evts[0].Run
+		for j, e := range evts {
+			if e.Run != run {
+				t.Errorf("subscriber %d: expected run %d got %d", i, run, e.Run)
+			}
+			if e.Seq != uint64(j+1) {
+				t.Errorf("subscriber %d: expected seq %d got %d", i, j+1, e.Seq)
+			}
+			if e.Done != (j == len(evts)-1) {
+				t.Errorf("subscriber %d: event %d: expected Done only on the final event", i, j)
+			}
+			if e != got[0][j] {
+				t.Errorf("subscriber %d: event %d differs from the first subscriber's", i, j)
+			}
+		}
+	}
+}
+
+func TestSubscribeRunsNumbered(t *testing.T) {
+	a := newTestAutotiler(t, testConfig())
+	defer a.Close()
+
+	events, cancel := a.Subscribe(100000)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		done, err := setLandAsync(t, a)
+		waitFor(t, done, "SetLand")
+		if *err != nil {
+			t.Fatal(*err)
+		}
+	}
+
+	runs := []uint64{}
+	seq := uint64(0)
+	for len(runs) < 2 {
+		e := <-events
+		seq++
+		if e.Seq != seq {
+			t.Fatalf("expected seq %d got %d", seq, e.Seq)
+		}
+		if e.Done {
+			runs = append(runs, e.Run)
+			seq = 0
+		}
+	}
+	if runs[0] == runs[1] {
+		t.Errorf("expected each run to have it's own id, got %v", runs)
+	}
+}
+
+func TestSubscribeCancelWhileSending(t *testing.T) {
+	a := newTestAutotiler(t, testConfig())
+	defer a.Close()
+
+	// this subscriber cancels from it's read loop, while the autotiler is blocked
+	// waiting to send it the next event
+	events, cancel := a.Subscribe(0)
+	cancelled := make(chan struct{})
+	go func() {
+		defer close(cancelled)
+		<-events
+		time.Sleep(10 * time.Millisecond) // let the next send block
+		cancel()
+		for range events { // drain until closed
+		}
+	}()
+
+	// this subscriber keeps reading & should see the run finish
+	other, cancelOther := a.Subscribe(0)
+	defer cancelOther()
+	sawDone := make(chan struct{})
+	go func() {
+		for e := range other {
+			if e.Done {
+				close(sawDone)
+				return
+			}
+		}
+	}()
+
+	done, err := setLandAsync(t, a)
+	waitFor(t, cancelled, "cancel")
+	waitFor(t, done, "SetLand")
+	if *err != nil {
+		t.Fatal(*err)
+	}
+	waitFor(t, sawDone, "Done event")
+}
+
+func TestCloseUnblocksSetLand(t *testing.T) {
+	a := newTestAutotiler(t, testConfig())
+
+	// never read, so the autotiler blocks sending to us
+	events, _ := a.Subscribe(0)
+
+	done, err := setLandAsync(t, a)
+	time.Sleep(10 * time.Millisecond)
+	a.Close()
+
+	waitFor(t, done, "SetLand")
+	if *err != nil {
+		t.Fatal(*err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		for range events {
+		}
+		close(closed)
+	}()
+	waitFor(t, closed, "events to close")
+
+	// subscribing after Close returns a closed channel
+	late, _ := a.Subscribe(0)
+	if _, ok := <-late; ok {
+		t.Error("expected a closed channel after Close")
+	}
+}
+
+func TestSubscribeFromReadLoop(t *testing.T) {
+	a := newTestAutotiler(t, testConfig())
+	defer a.Close()
+
+	// subscribing (and cancelling) while the autotiler is blocked sending to us
+	events, cancel := a.Subscribe(0)
+	defer cancel()
+	sawDone := make(chan struct{})
+	go func() {
+		first := true
+		for e := range events {
+			if first {
+				first = false
+				time.Sleep(10 * time.Millisecond) // let the next send block
+				_, cancelNew := a.Subscribe(0)
+				cancelNew()
+			}
+			if e.Done {
+				close(sawDone)
+				return
+			}
+		}
+	}()
+
+	done, err := setLandAsync(t, a)
+	waitFor(t, done, "SetLand")
+	if *err != nil {
+		t.Fatal(*err)
+	}
+	waitFor(t, sawDone, "Done event")
+}
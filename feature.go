@@ -0,0 +1,249 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+
+	"github.com/voidshard/tile"
+)
+
+// Feature is some structure spanning a number of tiles (waterfalls, stairs, docks,
+// fords ..) that is placed after land tiles, where some pattern in the land is found.
+//
+// During SetLand `Detect` is called for every tile, detected tiles that touch
+// (including diagonally) are merged into a single FeatureMatch which is passed to
+// `Resolve` to decide what to place.
+//
+// As with Placers, features are required to be thread safe & should only use the
+// given `rng` (or FeatureMatch.Fill) for random numbers. The `rng` is seeded by the
+// corner of the match, a match too large to be seen whole from a neighbouring region
+// should use FeatureMatch.Fill, which seeds each tile by it's own location.
+type Feature struct {
+	// Detect returns if the given Site is part of this feature.
+	// Can be nil, in which case the feature is only detected by built in passes
+	// (ie. when replacing a built in feature's Resolve).
+	Detect func(s *Site) bool
+
+	// Resolve returns the events (tiles) to set for the given match.
+	Resolve func(rng *rand.Rand, m *FeatureMatch) ([]*Event, error)
+}
+
+// FeatureMatch is a group of adjacent tiles where a Feature was detected
+type FeatureMatch struct {
+	// Name of the Feature
+	Name string
+
+	// Bounds is the smallest rectangle containing all Cells, as with any
+	// image.Rectangle Bounds.Max is exclusive (see Tileset.Fill).
+	Bounds image.Rectangle
+
+	// Cells where the feature was detected, in the order they were found
+	Cells []image.Point
+
+	o   Outline
+	cfg *Config
+	col *collision
+}
+
+// newFeatureMatch returns a FeatureMatch for the given collision
+func newFeatureMatch(o Outline, cfg *Config, col *collision) *FeatureMatch {
+	m := &FeatureMatch{
+		Name:   string(col.typ),
+		Bounds: col.bounds(),
+		Cells:  make([]image.Point, len(col.events)),
+		o:      o,
+		cfg:    cfg,
+		col:    col,
+	}
+	for i, e := range col.events {
+		m.Cells[i] = image.Pt(e.X, e.Y)
+	}
+	return m
+}
+
+// Site returns a Site at (x, y) with helpers to look at the land around it.
+func (m *FeatureMatch) Site(x, y int) *Site {
+	return newSite(m.o, m.cfg, newArea(m.o, x, y))
+}
+
+// Fill returns events filling the rectangle `r` (Max exclusive, as Bounds) with the
+// Tileset `t` on layer `z`, with edge & corner pieces around a Full middle.
+// Each tile is seeded by it's own location, so a feature is the same no matter
+// which region it's placed in.
+func (m *FeatureMatch) Fill(t *Tileset, r image.Rectangle, z int, props *tile.Properties) []*Event {
+	if r.Empty() {
+		return []*Event{}
+	}
+	rngAt := func(x, y int) *rand.Rand { return tileRand(m.cfg.Seed, x, y, m.Name) }
+	return t.fillRect(rngAt, image.Rect(r.Min.X, r.Min.Y, r.Max.X-1, r.Max.Y-1), z, props)
+}
+
+// tiles returns the LandTiles of the first cell found
+func (m *FeatureMatch) tiles() *LandTiles {
+	c := m.Cells[0]
+	return m.o.LandAt(c.X, c.Y).Tiles()
+}
+
+// namedFeature is a Feature & the name it was registered with
+type namedFeature struct {
+	name    string
+	feature *Feature
+}
+
+// defaultFeatures returns our built in features, most of these are detected by the
+// built in passes (rather than a Detect func).
+func (a *Autotiler) defaultFeatures() []*namedFeature {
+	fill := func(typ collisionType, ts func(*LandTiles) *Tileset, rect func(Outline, image.Rectangle) image.Rectangle, props *tile.Properties) *namedFeature {
+		return &namedFeature{name: string(typ), feature: &Feature{Resolve: a.fillFeature(ts, rect, props)}}
+	}
+	ramp := func(typ collisionType, ts, stairs func(*LandTiles) *Tileset, rect func(Outline, image.Rectangle) image.Rectangle, down Heading) *namedFeature {
+		return &namedFeature{name: string(typ), feature: &Feature{Resolve: a.rampFeature(ts, stairs, rect, down)}}
+	}
+
+	return []*namedFeature{
+		fill(collisionStairsNS, func(t *LandTiles) *Tileset { return t.StairsNorthSouth }, rectNS, propertiesRoad),
+		fill(collisionStairsSN, func(t *LandTiles) *Tileset { return t.StairsSouthNorth }, rectSame, propertiesRoad),
+		fill(collisionStairsEW, func(t *LandTiles) *Tileset { return t.StairsEastWest }, rectEW, propertiesRoad),
+		fill(collisionStairsWE, func(t *LandTiles) *Tileset { return t.StairsWestEast }, rectEW, propertiesRoad),
+
+		ramp(collisionRampNS, func(t *LandTiles) *Tileset { return t.RampNorthSouth }, func(t *LandTiles) *Tileset { return t.StairsNorthSouth }, rectNS, South),
+		ramp(collisionRampSN, func(t *LandTiles) *Tileset { return t.RampSouthNorth }, func(t *LandTiles) *Tileset { return t.StairsSouthNorth }, rectSame, North),
+		ramp(collisionRampEW, func(t *LandTiles) *Tileset { return t.RampEastWest }, func(t *LandTiles) *Tileset { return t.StairsEastWest }, rectEW, West),
+		ramp(collisionRampWE, func(t *LandTiles) *Tileset { return t.RampWestEast }, func(t *LandTiles) *Tileset { return t.StairsWestEast }, rectEW, East),
+
+		fill(collisionWaterfallNS, func(t *LandTiles) *Tileset { return t.WaterfallNorthSouth }, rectSame, propertiesWFall),
+		fill(collisionWaterfallSN, func(t *LandTiles) *Tileset { return t.WaterfallSouthNorth }, rectSN, propertiesWFall),
+		fill(collisionWaterfallEW, func(t *LandTiles) *Tileset { return t.WaterfallEastWest }, rectSame, propertiesWFall),
+		fill(collisionWaterfallWE, func(t *LandTiles) *Tileset { return t.WaterfallWestEast }, rectSame, propertiesWFall),
+
+		{name: string(collisionLavaWater), feature: &Feature{Resolve: func(rng *rand.Rand, m *FeatureMatch) ([]*Event, error) {
+			return a.lavaWater(m.o, m.tiles(), m.col), nil
+		}}},
+
+		{name: string(collisionDock), feature: &Feature{Detect: a.detectDock, Resolve: a.resolveDock}},
+	}
+}
+
+// rectSame, rectNS, rectSN & rectEW adjust a FeatureMatch's Bounds to cover the
+// whole of a cliff face for the various built in features.
+func rectSame(o Outline, r image.Rectangle) image.Rectangle { return r }
+
+func rectNS(o Outline, r image.Rectangle) image.Rectangle {
+	return image.Rect(r.Min.X, r.Min.Y-1, r.Max.X, r.Max.Y+1)
+}
+
+func rectSN(o Outline, r image.Rectangle) image.Rectangle {
+	return image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y+1)
+}
+
+func rectEW(o Outline, r image.Rectangle) image.Rectangle {
+	if r.Dy() == 1 { // a single road, the stairs also cover the tile above
+		return image.Rect(r.Min.X, r.Min.Y-1, r.Max.X, r.Max.Y)
+	}
+	return image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Max.Y-1)
+}
+
+// fillFeature returns a Resolve func that fills the (adjusted) collision rectangle
+// with the Tileset chosen by `ts` on the waterfall layer.
+func (a *Autotiler) fillFeature(ts func(*LandTiles) *Tileset, rect func(Outline, image.Rectangle) image.Rectangle, props *tile.Properties) func(*rand.Rand, *FeatureMatch) ([]*Event, error) {
+	return func(_ *rand.Rand, m *FeatureMatch) ([]*Event, error) {
+		tiles := m.tiles()
+		if tiles == nil {
+			return nil, nil
+		}
+		t := ts(tiles)
+		if t == nil {
+			return nil, nil
+		}
+		return m.Fill(t, rect(m.o, m.Bounds), a.cfg.ZOffsetWaterfall, props), nil
+	}
+}
+
+// rampFeature returns a Resolve func that fills the collision rectangle, made long
+// enough for the height of the cliff, with the ramp Tileset chosen by `ts`. Where the
+// ramp would be too long we place stairs (chosen by `stairs`) instead.
+func (a *Autotiler) rampFeature(ts, stairs func(*LandTiles) *Tileset, rect func(Outline, image.Rectangle) image.Rectangle, down Heading) func(*rand.Rand, *FeatureMatch) ([]*Event, error) {
+	return func(_ *rand.Rand, m *FeatureMatch) ([]*Event, error) {
+		tiles := m.tiles()
+		if tiles == nil {
+			return nil, nil
+		}
+		r, ok := a.rampRect(m.o, rect(m.o, m.Bounds), down)
+		t := ts(tiles)
+		if !ok {
+			t = stairs(tiles)
+		}
+		if t == nil {
+			return nil, nil
+		}
+		return m.Fill(t, r, a.cfg.ZOffsetWaterfall, propertiesRoad), nil
+	}
+}
+
+// RegisterFeature adds a Feature that is detected & resolved during SetLand.
+// Registering a feature with an existing name replaces it, including the built in
+// features which are named after what they place & the direction they face
+// (eg. "waterfall-ns", "stairs-we", "ramp-sn", "lava-water", "dock").
+//
+// Nb. this should not be called while the Autotiler is in use.
+func (a *Autotiler) RegisterFeature(name string, f *Feature) error {
+	if name == "" {
+		return fmt.Errorf("%s: feature name is required", ErrMissingRequiredValue)
+	}
+	if f == nil || f.Resolve == nil {
+		return fmt.Errorf("%s: feature %s requires Resolve", ErrMissingRequiredValue, name)
+	}
+
+	features := []*namedFeature{}
+	for _, nf := range a.features {
+		if nf.name != name {
+			features = append(features, nf)
+		}
+	}
+	a.features = append(features, &namedFeature{name: name, feature: f})
+
+	return nil
+}
+
+// feature returns the feature registered with the given name, if any
+func (a *Autotiler) feature(name string) *Feature {
+	for _, nf := range a.features {
+		if nf.name == name {
+			return nf.feature
+		}
+	}
+	return nil
+}
+
+// detectFeatures returns collision events for each registered feature detected at `s`
+func (a *Autotiler) detectFeatures(s *Site) []*Event {
+	evts := []*Event{}
+	for _, nf := range a.features {
+		if nf.feature.Detect != nil && nf.feature.Detect(s) {
+			evts = append(evts, newColEvent(s.X, s.Y, 0, collisionType(nf.name)))
+		}
+	}
+	return evts
+}
+
+// handleCollision resolves a collision using the feature of the same name
+func (a *Autotiler) handleCollision(o Outline, col *collision) ([]*Event, error) {
+	f := a.feature(string(col.typ))
+	if f == nil {
+		return nil, nil
+	}
+
+	r := col.Max()
+	rng := tileRand(a.cfg.Seed, r.Min.X, r.Min.Y, string(col.typ))
+
+	m := newFeatureMatch(o, a.cfg, col)
+	evts, err := f.Resolve(rng, m)
+	if err != nil {
+		return nil, err
+	}
+
+	a.animate(m.tiles(), evts)
+	a.seenLayers(evts)
+	return evts, nil
+}
@@ -0,0 +1,85 @@
+package autotile
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFeatureMatchFill(t *testing.T) {
+	ts := &Tileset{
+		Full:             []string{"full", "full2", "full3"},
+		QuarterNorthEast: []string{"ne"},
+		QuarterNorthWest: []string{"nw"},
+		QuarterSouthEast: []string{"se"},
+		QuarterSouthWest: []string{"sw"},
+		NorthHalf:        []string{"n"},
+		EastHalf:         []string{"e"},
+		SouthHalf:        []string{"s"},
+		WestHalf:         []string{"w"},
+	}
+	m := &FeatureMatch{Name: "test", cfg: &Config{Seed: 3}}
+	r := image.Rect(2, 3, 12, 17)
+
+	evts := m.Fill(ts, r, 4, nil)
+	if len(evts) != r.Dx()*r.Dy() {
+		t.Fatalf("expected %d events got %d", r.Dx()*r.Dy(), len(evts))
+	}
+	full := map[image.Point]string{}
+	for _, e := range evts {
+		if !image.Pt(e.X, e.Y).In(r) {
+			t.Errorf("event at (%d,%d) outside of %v", e.X, e.Y, r)
+		}
+		if e.Z != 4 {
+			t.Errorf("expected z 4 got %d", e.Z)
+		}
+		full[image.Pt(e.X, e.Y)] = e.Src
+	}
+
+	// tiles in the middle are the same no matter where the rect starts
+	for _, e := range m.Fill(ts, image.Rect(0, 0, 12, 17), 4, nil) {
+		p := image.Pt(e.X, e.Y)
+		if p.In(r.Inset(1)) && full[p] != e.Src {
+			t.Errorf("expected %s at %v got %s", full[p], p, e.Src)
+		}
+	}
+
+	if evts := m.Fill(ts, image.Rect(1, 1, 1, 4), 4, nil); len(evts) != 0 {
+		t.Errorf("expected no events for an empty rect, got %d", len(evts))
+	}
+}
+
+func TestFeatureMatchBounds(t *testing.T) {
+	h := newCollisionHandler()
+	h.append(newColEvent(3, 4, 0, collisionDock))
+	h.append(newColEvent(4, 4, 0, collisionDock))
+	h.append(newColEvent(4, 5, 0, collisionDock))
+	cols := h.All()
+	if len(cols) != 1 {
+		t.Fatalf("expected 1 collision got %d", len(cols))
+	}
+	col := cols[0]
+
+	m := newFeatureMatch(nil, nil, col)
+	want := image.Rect(3, 4, 5, 6)
+	if m.Bounds != want {
+		t.Errorf("expected bounds %v got %v", want, m.Bounds)
+	}
+	for _, c := range m.Cells {
+		if !c.In(m.Bounds) {
+			t.Errorf("cell %v outside of bounds %v", c, m.Bounds)
+		}
+	}
+}
+
+func TestRectEW(t *testing.T) {
+	// a single road's stairs also cover the tile above
+	got := rectEW(nil, image.Rect(2, 5, 4, 6))
+	if want := image.Rect(2, 4, 4, 6); got != want {
+		t.Errorf("expected %v got %v", want, got)
+	}
+
+	got = rectEW(nil, image.Rect(2, 5, 4, 8))
+	if want := image.Rect(2, 5, 4, 7); got != want {
+		t.Errorf("expected %v got %v", want, got)
+	}
+}
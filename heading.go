@@ -14,3 +14,27 @@ const (
 	West      Heading = 6
 	NorthWest Heading = 7
 )
+
+// Offset returns the (x, y) offset of the adjacent tile in this direction.
+// North is -y (up).
+func (h Heading) Offset() (int, int) {
+	switch h {
+	case North:
+		return 0, -1
+	case NorthEast:
+		return 1, -1
+	case East:
+		return 1, 0
+	case SouthEast:
+		return 1, 1
+	case South:
+		return 0, 1
+	case SouthWest:
+		return -1, 1
+	case West:
+		return -1, 0
+	case NorthWest:
+		return -1, -1
+	}
+	return 0, 0
+}
@@ -1,6 +1,8 @@
 package autotile
 
 import (
+	"image"
+
 	"github.com/voidshard/tile"
 )
 
@@ -9,6 +11,28 @@ type Outline interface {
 	LandAt(x, y int) LandData
 }
 
+// RegionOutline is an Outline that can return the LandData for many tiles at
+// once. If an Outline implements this we fetch all of the data we need for a
+// region (plus some padding around it) in one call & read from memory after that.
+type RegionOutline interface {
+	Outline
+
+	// LandRect returns the LandData for every tile in `r`, indexed [y][x]
+	// relative to r.Min (ie. [0][0] is the LandData at r.Min).
+	LandRect(r image.Rectangle) [][]LandData
+}
+
+// ConcurrentOutline is an Outline that is safe to call from many routines at
+// once. LandAt is never called concurrently on Outlines that don't implement this,
+// but the LandData they return may still be read from many routines at once
+// (see SetLandParallel).
+type ConcurrentOutline interface {
+	Outline
+
+	// ConcurrentSafe is a marker, it is never called.
+	ConcurrentSafe()
+}
+
 // LandData represents information about the natural world at a given location
 type LandData interface {
 	// Asks if the given tile is one of these things.
@@ -34,6 +58,13 @@ type LandData interface {
 	Tags() []string
 }
 
+// RampPreference may optionally be implemented by LandData to choose between ramps
+// and stairs where a road crosses a cliff, overriding Config.PreferRamps.
+type RampPreference interface {
+	// PreferRamp returns true if a ramp should be placed rather than stairs
+	PreferRamp() bool
+}
+
 // ObjectBin is something that can decide what object should be placed at
 // a given location on a map.
 type ObjectBin interface {
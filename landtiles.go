@@ -5,6 +5,7 @@ import (
 
 	"image"
 	"math/rand"
+	"reflect"
 	"sort"
 )
 
@@ -25,6 +26,10 @@ type LandTiles struct {
 	// Sand is used for deserts, beaches etc
 	Sand *Tileset
 
+	// WetSand is used instead of sand on beaches within Config.WetSandWidth
+	// tiles of water.
+	WetSand *Tileset
+
 	// Dirt is placed as a fallback, or when grass cannot be placed & nothing
 	// else applies
 	Dirt *Tileset
@@ -32,28 +37,71 @@ type LandTiles struct {
 	// Snow is placed instead of grass when temperature is low
 	Snow *Tileset
 
+	// Swamp is placed instead of grass where rainfall is high (see Config.SwampMinRainfall)
+	Swamp *Tileset
+
+	// Mud is placed where land is wet, but not yet swamp
+	Mud *Tileset
+
+	// DryGrass is placed instead of grass where rainfall is low (see Config.AridMaxRainfall)
+	DryGrass *Tileset
+
 	// Rock is placed generally instead of dirt, or when we're high up (barren mountains)
 	Rock *Tileset
 
 	// Water is placed where ever there is .. well .. water
 	Water *Tileset
 
+	// ShallowWater is placed instead of Water where water isn't deep, if either
+	// Config.SeaLevel or Config.DeepWaterDistance are set.
+	ShallowWater *Tileset
+
+	// DeepWater is placed where water is deep (see Config.SeaLevel & Config.DeepWaterDistance).
+	// Pieces are chosen as if all non deep tiles are shallow water, so the "empty"
+	// parts of these tiles should be shallow water.
+	DeepWater *Tileset
+
+	// Ice is placed instead of water where the temperature is at or below
+	// Config.SnowLevel. Pieces have edges where ice meets land or open water.
+	Ice *Tileset
+
+	// FrozenEdge is placed instead of Ice where frozen water meets open water,
+	// pieces are chosen as if the ice continues over any adjacent land.
+	FrozenEdge *Tileset
+
 	// Bridge composed of tiles (stepping stones, planks or something that tiles well
-	// is recommended). Placed where water + road intersect.
+	// is recommended). Placed where a road crosses water, lava or null tiles.
 	Bridge *Tileset
 
+	// LavaBridge is placed instead of Bridge where a road crosses lava
+	LavaBridge *Tileset
+
+	// Dock is placed over water where a road ends at the shore (see Config.DockLength)
+	Dock *Tileset
+
 	// Road is placed where road is true
 	Road *Tileset
 
 	// Lava is placed where molten is true
 	Lava *Tileset
 
-	// Cliff is placed above config.CliffLevel where the land changes height.
-	// Note we only place cliffs every few height changes.
-	// TODO: improve so we place cliffs only with height changes above a
-	// certain sharpness.
+	// Obsidian is placed instead of lava where lava meets water, pieces are chosen
+	// as they would be for lava.
+	Obsidian *Tileset
+
+	// Steam is placed over water where it meets lava (see Config.ZOffsetSteam)
+	Steam *Tileset
+
+	// Cliff is placed where the land drops by more than Config.CliffMinDelta, either
+	// above Config.CliffLevel or (if Config.CliffStep is set) wherever the land
+	// drops to a lower terrace.
 	Cliff *Tileset
 
+	// CliffTop is placed on the high side of a cliff (a lip or overhang), on tiles
+	// next to a cliff face at the same height. Pieces are chosen so the edge of
+	// the tile faces the cliff.
+	CliffTop *Tileset
+
 	// Stairs are placed where roads meet cliffs.
 	StairsWestEast *Tileset
 
@@ -66,6 +114,22 @@ type LandTiles struct {
 	//
 	StairsSouthNorth *Tileset
 
+	// Ramps are placed where roads meet cliffs, instead of stairs if we prefer
+	// ramps (see Config.PreferRamps). Named for the direction they descend, so
+	// RampNorthSouth is high at the North end.
+	// Where a ramp would be longer than we can place (see Config.RampRise) the
+	// matching stairs are placed instead.
+	RampNorthSouth *Tileset
+
+	// RampSouthNorth descends to the North, so is high at the South end.
+	RampSouthNorth *Tileset
+
+	// RampEastWest descends to the West, so is high at the East end.
+	RampEastWest *Tileset
+
+	// RampWestEast descends to the East, so is high at the West end.
+	RampWestEast *Tileset
+
 	// Waterfall is where a cliff & a river intersect one another.
 	WaterfallNorthSouth *Tileset
 
@@ -84,6 +148,48 @@ func (b *LandTiles) Validate() error {
 	return nil
 }
 
+// tilesetFields maps the names of our Tileset fields to their index
+var tilesetFields = map[string]int{}
+
+// tilesetIndexes are the indexes of our Tileset fields, in order
+var tilesetIndexes = []int{}
+
+func init() {
+	lt := reflect.TypeOf(LandTiles{})
+	ts := reflect.TypeOf(&Tileset{})
+	for i := 0; i < lt.NumField(); i++ {
+		if lt.Field(i).Type == ts {
+			tilesetFields[lt.Field(i).Name] = i
+			tilesetIndexes = append(tilesetIndexes, i)
+		}
+	}
+}
+
+// isTilesetName returns if `name` is the name of one of our Tilesets
+func isTilesetName(name string) bool {
+	_, ok := tilesetFields[name]
+	return ok
+}
+
+// Tileset returns the Tileset with the given field name (eg. "Grass") or nil
+// if it isn't set (or there is no such Tileset).
+func (b *LandTiles) Tileset(name string) *Tileset {
+	i, ok := tilesetFields[name]
+	if !ok {
+		return nil
+	}
+	return reflect.ValueOf(b).Elem().Field(i).Interface().(*Tileset)
+}
+
+// tilesets returns the Tilesets with the given names, in order
+func (b *LandTiles) tilesets(names []string) []*Tileset {
+	sets := make([]*Tileset, len(names))
+	for i, name := range names {
+		sets[i] = b.Tileset(name)
+	}
+	return sets
+}
+
 // Tileset represents tiles that must be placed depending on
 // what tile(s) of the same terrain are adjacent to them.
 // Ie. water or road tiles that are supposed to fit together to form
@@ -135,42 +241,119 @@ type Tileset struct {
 
 	// 3/4 of the tile is the complex type, centred on the NW corner.
 	ThreeQuarterNorthWest []string
+
+	// Blob optionally holds the full 47 tile "blob" layout, which can show one tile
+	// wide paths, junctions, dead ends & thin corners.
+	// Keys are masks of the adjacent tiles of the same type (see BlobMask & BlobMasks),
+	// where we don't have a tile for some mask we fall back to the pieces above.
+	Blob map[int][]string
+
+	// Wang if set is used instead of all of the above, see WangCornerTileset
+	Wang *WangCornerTileset
+
+	// Weights optionally makes some tiles more (or less) likely to be chosen than
+	// others of the same piece, keyed by tile (image path). Tiles without a weight
+	// have a weight of 1.
+	// Ie. with Full: ["plain.png", "cracked.png"] a weight of 49 for "plain.png"
+	// means "cracked.png" is placed 2% of the time.
+	Weights map[string]float64
+
+	// Animations optionally makes tiles animated, keyed by tile (image path).
+	// When an animated tile is set we write it's frames to the tile property
+	// "animation" as a comma separated list of "src:milliseconds" & add each
+	// frame to the map tileset.
+	// Nb. the tile library can't write Tiled <animation> elements itself, use
+	// EncodeTMX or WriteTMXFile to write the map with them.
+	Animations map[string][]Frame
 }
 
-//
-func (t *Tileset) fillRect(rng *rand.Rand, in image.Rectangle, z int, props *tile.Properties) []*Event {
+// Pick chooses one of the given tiles at random, honouring our Weights
+func (t *Tileset) Pick(rng *rand.Rand, items []string) string {
+	if len(t.Weights) == 0 || len(items) < 2 {
+		return one(rng, items)
+	}
+
+	total := 0.0
+	for _, item := range items {
+		total += t.weight(item)
+	}
+	if total <= 0 {
+		return one(rng, items)
+	}
+
+	r := rng.Float64() * total
+	for _, item := range items {
+		r -= t.weight(item)
+		if r < 0 {
+			return item
+		}
+	}
+	return items[len(items)-1]
+}
+
+// weight returns the weight of the given tile
+func (t *Tileset) weight(src string) float64 {
+	w, ok := t.Weights[src]
+	if !ok {
+		return 1
+	}
+	if w < 0 {
+		return 0
+	}
+	return w
+}
+
+// full returns our Full pieces
+func (t *Tileset) full() []string {
+	if t.Wang != nil {
+		return t.Wang.Pieces[15]
+	}
+	return t.Full
+}
+
+// fillRect fills the rectangle `in` (including it's max), each tile gets it's own
+// random numbers from `rngAt`.
+func (t *Tileset) fillRect(rngAt func(x, y int) *rand.Rand, in image.Rectangle, z int, props *tile.Properties) []*Event {
 	evts := []*Event{}
 
 	for y := in.Max.Y; y >= in.Min.Y; y-- {
 		for x := in.Min.X; x <= in.Max.X; x++ {
+			rng := rngAt(x, y)
 			src := ""
+			if t.Wang != nil {
+				src = t.Pick(rng, t.Wang.Pieces[WangRectMask(in, x, y)])
+				if src != "" {
+					evts = append(evts, newEvent(x, y, z, src, props))
+				}
+				continue
+			}
 			switch y {
 			case in.Max.Y: // place max y first so we tile from obj bottom
 				switch x {
 				case in.Min.X:
-					src = one(rng, t.QuarterNorthEast)
+					src = t.Pick(rng, t.QuarterNorthEast)
 				case in.Max.X:
-					src = one(rng, t.QuarterNorthWest)
+					src = t.Pick(rng, t.QuarterNorthWest)
 				default:
-					src = one(rng, t.NorthHalf)
+					src = t.Pick(rng, t.NorthHalf)
 				}
 			case in.Min.Y:
 				switch x {
 				case in.Min.X:
-					src = one(rng, t.QuarterSouthEast)
+					src = t.Pick(rng, t.QuarterSouthEast)
 				case in.Max.X:
-					src = one(rng, t.QuarterSouthWest)
+					src = t.Pick(rng, t.QuarterSouthWest)
 				default:
-					src = one(rng, t.SouthHalf)
+					src = t.Pick(rng, t.SouthHalf)
 				}
 			default:
 				switch x {
 				case in.Min.X:
-					src = one(rng, t.EastHalf)
+					src = t.Pick(rng, t.EastHalf)
 				case in.Max.X:
-					src = one(rng, t.WestHalf)
+					src = t.Pick(rng, t.WestHalf)
 				default:
-					src = one(rng, t.Full)
+					src = t.Pick(rng, t.Full)
 				}
 			}
 			if src != "" {
@@ -185,6 +368,12 @@ func (t *Tileset) fillRect(rng *rand.Rand, in image.Rectangle, z int, props *til
 // choosePiece decides which piece of our ComplexLand to place given which of the
 // surrounding pieces are 'isSet' (this complex land) or 'notSet' (not this).
 func (t *Tileset) choosePiece(rng *rand.Rand, crd *nearby, isIn func(a *area) bool) string {
+	if t.Wang != nil {
+		// the tile we're choosing a piece for is always of this terrain
+		mask := wangStripMask(func(dx, dy int) bool { return isIn(crd.at(dx, dy)) })
+		return t.Pick(rng, t.Wang.Pieces[mask])
+	}
+
 	isSet := []*area{}
 	notSet := []*area{}
 
@@ -201,65 +390,71 @@ func (t *Tileset) choosePiece(rng *rand.Rand, crd *nearby, isIn func(a *area) bo
 		}
 	}
 
+	if len(t.Blob) > 0 {
+		if src, ok := t.blobPiece(rng, in); ok {
+			return src
+		}
+	}
+
 	sort.Slice(in, func(i, j int) bool { return int(in[i]) < int(in[j]) })
 	sort.Slice(out, func(i, j int) bool { return int(out[i]) < int(out[j]) })
 
 	if len(isSet) >= 8 { // we can't actually have more than 8
-		return one(rng, t.Full)
+		return t.Pick(rng, t.Full)
 	} else if len(isSet) == 7 { // implies notSet len == 1
 		lt := notSet[0] // ie. this is the only element
 		switch lt.heading {
 		case NorthEast:
-			return one(rng, t.ThreeQuarterSouthWest)
+			return t.Pick(rng, t.ThreeQuarterSouthWest)
 		case SouthEast:
-			return one(rng, t.ThreeQuarterNorthWest)
+			return t.Pick(rng, t.ThreeQuarterNorthWest)
 		case SouthWest:
-			return one(rng, t.ThreeQuarterNorthEast)
+			return t.Pick(rng, t.ThreeQuarterNorthEast)
 		case NorthWest:
-			return one(rng, t.ThreeQuarterSouthEast)
+			return t.Pick(rng, t.ThreeQuarterSouthEast)
 		case North:
-			return one(rng, t.SouthHalf)
+			return t.Pick(rng, t.SouthHalf)
 		case East:
-			return one(rng, t.WestHalf)
+			return t.Pick(rng, t.WestHalf)
 		case South:
-			return one(rng, t.NorthHalf)
+			return t.Pick(rng, t.NorthHalf)
 		case West:
-			return one(rng, t.EastHalf)
+			return t.Pick(rng, t.EastHalf)
 		}
 	} else if len(isSet) <= 6 {
 		// try to place corners first, then edges ..
 		if includes(out, cornerNE...) {
 			if includes(in, edgeSW...) {
-				return one(rng, t.ThreeQuarterSouthWest)
+				return t.Pick(rng, t.ThreeQuarterSouthWest)
 			} else {
-				return one(rng, t.QuarterSouthWest)
+				return t.Pick(rng, t.QuarterSouthWest)
 			}
 		} else if includes(out, cornerSE...) {
 			if includes(in, edgeNW...) {
-				return one(rng, t.ThreeQuarterNorthWest)
+				return t.Pick(rng, t.ThreeQuarterNorthWest)
 			} else {
-				return one(rng, t.QuarterNorthWest)
+				return t.Pick(rng, t.QuarterNorthWest)
 			}
 		} else if includes(out, cornerSW...) {
 			if includes(in, edgeNE...) {
-				return one(rng, t.ThreeQuarterNorthEast)
+				return t.Pick(rng, t.ThreeQuarterNorthEast)
 			} else {
-				return one(rng, t.QuarterNorthEast)
+				return t.Pick(rng, t.QuarterNorthEast)
 			}
 		} else if includes(out, cornerNW...) {
 			if includes(in, edgeSE...) {
-				return one(rng, t.ThreeQuarterSouthEast)
+				return t.Pick(rng, t.ThreeQuarterSouthEast)
 			} else {
-				return one(rng, t.QuarterSouthEast)
+				return t.Pick(rng, t.QuarterSouthEast)
 			}
 		} else if includes(out, North) {
-			return one(rng, t.SouthHalf)
+			return t.Pick(rng, t.SouthHalf)
 		} else if includes(out, East) {
-			return one(rng, t.WestHalf)
+			return t.Pick(rng, t.WestHalf)
 		} else if includes(out, South) {
-			return one(rng, t.NorthHalf)
+			return t.Pick(rng, t.NorthHalf)
 		} else if includes(out, West) {
-			return one(rng, t.EastHalf)
+			return t.Pick(rng, t.EastHalf)
 		}
 	}
 
@@ -0,0 +1,204 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/tile"
+)
+
+// testTiles is a LandTiles with every Tileset set, each piece has a few variants
+// named after the Tileset & piece it is (eg. "Water.NorthHalf.2"), so tests see
+// any difference in how tiles are chosen at random.
+var testTiles = newTestTiles()
+
+func newTestTiles() *LandTiles {
+	tiles := &LandTiles{}
+	lt := reflect.ValueOf(tiles).Elem()
+	for i := 0; i < lt.NumField(); i++ {
+		if lt.Field(i).Type() != reflect.TypeOf(&Tileset{}) {
+			continue
+		}
+		ts := &Tileset{}
+		tv := reflect.ValueOf(ts).Elem()
+		for j := 0; j < tv.NumField(); j++ {
+			f := tv.Type().Field(j)
+			if f.Type != reflect.TypeOf([]string{}) {
+				continue
+			}
+			variants := []string{}
+			for k := 0; k < 3; k++ {
+				variants = append(variants, fmt.Sprintf("%s.%s.%d", lt.Type().Field(i).Name, f.Name, k))
+			}
+			tv.Field(j).Set(reflect.ValueOf(variants))
+		}
+		lt.Field(i).Set(reflect.ValueOf(ts))
+	}
+	return tiles
+}
+
+// testLand is a LandData for tests
+type testLand struct {
+	height, temp, rain  int
+	water, road, molten bool
+}
+
+func (l *testLand) IsLand() bool      { return !l.water && !l.molten }
+func (l *testLand) IsWater() bool     { return l.water }
+func (l *testLand) IsMolten() bool    { return l.molten }
+func (l *testLand) IsRoad() bool      { return l.road }
+func (l *testLand) IsNull() bool      { return false }
+func (l *testLand) Height() int       { return l.height }
+func (l *testLand) Rainfall() int     { return l.rain }
+func (l *testLand) Temperature() int  { return l.temp }
+func (l *testLand) Tiles() *LandTiles { return testTiles }
+func (l *testLand) Tags() []string    { return nil }
+
+// testOutline is a random (but repeatable) Outline of hills, cliffs, lakes, lava
+// & roads, where `changed` overrides what is at a given location.
+type testOutline struct {
+	changed map[image.Point]*testLand
+}
+
+func newTestOutline() *testOutline {
+	return &testOutline{changed: map[image.Point]*testLand{}}
+}
+
+// ConcurrentSafe marks that LandAt can be called from many routines (so long as
+// `changed` isn't written at the same time)
+func (o *testOutline) ConcurrentSafe() {}
+
+// hash returns a repeatable number [0, 1000) for the given values
+func hash(x, y, k int) int {
+	v := uint64(x*73856093) ^ uint64(y*19349663) ^ uint64(k*83492791)
+	v ^= v >> 13
+	v *= 0x9E3779B97F4A7C15
+	v ^= v >> 29
+	return int(v % 1000)
+}
+
+func (o *testOutline) LandAt(x, y int) LandData {
+	if l, ok := o.changed[image.Pt(x, y)]; ok {
+		return l
+	}
+
+	// land is decided in blocks, so we get areas of similar tiles
+	bx, by := floorDiv(x, 5), floorDiv(y, 5)
+	kind := hash(bx, by, 4)
+	return &testLand{
+		height: hash(bx, by, 1) % 256,
+		temp:   hash(floorDiv(bx, 3), floorDiv(by, 3), 2)%60 - 15,
+		rain:   hash(floorDiv(bx, 2), floorDiv(by, 2), 3) % 300,
+		water:  kind < 150 || (kind < 180 && hash(x, y, 5) < 500),
+		molten: kind >= 980,
+		road:   floorMod(y, 13) == 0 || floorMod(x, 17) == 0,
+	}
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+	return a / b
+}
+
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
+
+// testConfig returns a config that places most kinds of tiles given a testOutline
+func testConfig() *Config {
+	return &Config{
+		Seed:              7,
+		BeachWidth:        2,
+		WetSandWidth:      1,
+		TransitionWidth:   3,
+		VegetationMaxTemp: 40,
+		VegetationMinTemp: 0,
+		MountainLevel:     230,
+		CliffLevel:        120,
+		CliffMinDelta:     10,
+		SnowLevel:         -10,
+		SwampMinRainfall:  250,
+		AridMaxRainfall:   40,
+		PreferRamps:       true,
+		RampRise:          60,
+		DockLength:        3,
+	}
+}
+
+// newTestAutotiler returns an Autotiler for the given config, failing the test if
+// the config is invalid
+func newTestAutotiler(t *testing.T, cfg *Config) *Autotiler {
+	t.Helper()
+	a, err := NewAutotiler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// newTestMap returns an empty map of the given size
+func newTestMap(width, height int) *tile.Map {
+	return tile.New(&tile.Config{TileWidth: 16, TileHeight: 16, MapWidth: uint(width), MapHeight: uint(height)})
+}
+
+// dumpMap returns the tiles set within `r` on every layer up to ZOffsetObject
+func dumpMap(t *testing.T, cfg *Config, m tile.Tileable, r image.Rectangle) []string {
+	t.Helper()
+	out := []string{}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			for z := 0; z <= cfg.ZOffsetObject; z++ {
+				src, err := m.At(x, y, z)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if src != "" {
+					out = append(out, fmt.Sprintf("(%d,%d,%d) %s", x, y, z, src))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// assertSameTiles fails the test if `got` & `want` have different tiles in `r`
+func assertSameTiles(t *testing.T, cfg *Config, want, got tile.Tileable, r image.Rectangle) {
+	t.Helper()
+	w := dumpMap(t, cfg, want, r)
+	g := dumpMap(t, cfg, got, r)
+	if len(w) == 0 {
+		t.Fatal("expected some tiles to be set")
+	}
+	if strings.Join(w, "\n") == strings.Join(g, "\n") {
+		return
+	}
+
+	wantSet := map[string]bool{}
+	for _, s := range w {
+		wantSet[s] = true
+	}
+	gotSet := map[string]bool{}
+	for _, s := range g {
+		gotSet[s] = true
+	}
+	diffs := []string{}
+	for _, s := range w {
+		if !gotSet[s] {
+			diffs = append(diffs, "missing "+s)
+		}
+	}
+	for _, s := range g {
+		if !wantSet[s] {
+			diffs = append(diffs, "unexpected "+s)
+		}
+	}
+	if len(diffs) > 10 {
+		diffs = append(diffs[:10], fmt.Sprintf("... and %d more", len(diffs)-10))
+	}
+	t.Errorf("tiles differ:\n%s", strings.Join(diffs, "\n"))
+}
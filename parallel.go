@@ -0,0 +1,159 @@
+package autotile
+
+import (
+	"context"
+	"image"
+	"sync"
+
+	"github.com/voidshard/tile"
+)
+
+// parallelChunkSize is the width & height (in tiles) of the chunks SetLandParallel
+// splits regions into.
+const parallelChunkSize = 32
+
+// chunkResult holds the output of placing a single chunk
+type chunkResult struct {
+	evts []*Event
+	err  error
+	done chan struct{}
+}
+
+// lockedOutline wraps an Outline that isn't known to be thread safe so that
+// only one routine calls LandAt at a time. The LandData returned isn't wrapped.
+type lockedOutline struct {
+	lock sync.Mutex
+	o    Outline
+}
+
+// LandAt calls the underlying Outline LandAt while holding our lock
+func (l *lockedOutline) LandAt(x, y int) LandData {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.o.LandAt(x, y)
+}
+
+// SetLandParallel is SetLand but the region is split into chunks, each of which
+// is placed by one of `workers` routines.
+// Tiles are written to `t` (and events emitted) from a single routine in
+// chunk order. Since tiles are seeded individually (see Config.Seed) the output
+// is the same no matter how many workers are used.
+//
+// Nb. The Outline `o` is only called concurrently if it implements ConcurrentOutline,
+// otherwise calls to LandAt are serialised. Either way the LandData returned is read
+// by all workers at once, so it must be safe to read concurrently (eg. immutable).
+func (a *Autotiler) SetLandParallel(o Outline, region image.Rectangle, t tile.Tileable, workers int) error {
+	return a.SetLandParallelContext(context.Background(), o, region, t, workers, nil)
+}
+
+// SetLandParallelContext is SetLandParallel but returns early with the context error if
+// `ctx` is cancelled. If `progress` is given it's called as chunks are completed.
+func (a *Autotiler) SetLandParallelContext(ctx context.Context, o Outline, region image.Rectangle, t tile.Tileable, workers int, progress ProgressFunc) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if _, ok := o.(ConcurrentOutline); !ok && workers > 1 {
+		o = &lockedOutline{o: o}
+	}
+
+	r := a.newRun(region)
+	defer a.finish(r)
+
+	chunks := chunkRegion(region, parallelChunkSize)
+	results := make([]*chunkResult, len(chunks))
+	for i := range results {
+		results[i] = &chunkResult{done: make(chan struct{})}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	work := make(chan int)
+	go func() {
+		defer close(work)
+		for i := range chunks {
+			select {
+			case work <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for i := range work {
+				res := results[i]
+				res.evts, res.err = a.placeChunk(ctx, o, chunks[i])
+				close(res.done)
+			}
+		}()
+	}
+
+	// enact chunks in order, as they become available
+	collisions := newCollisionHandler()
+	total := region.Dx() * region.Dy()
+	done := 0
+
+	for i, res := range results {
+		select {
+		case <-res.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if res.err != nil {
+			return res.err
+		}
+		err := a.enact(t, r, collisions, res.evts)
+		if err != nil {
+			return err
+		}
+		res.evts = nil // we're done with these
+
+		done += chunks[i].Dx() * chunks[i].Dy()
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	err := a.detectCollisions(ctx, o, region, collisions)
+	if err != nil {
+		return err
+	}
+
+	return a.resolveCollisions(o, r, t, collisions)
+}
+
+// placeChunk runs our placement functions over the given chunk & returns all events
+// in row order.
+func (a *Autotiler) placeChunk(ctx context.Context, o Outline, chunk image.Rectangle) ([]*Event, error) {
+	o = a.prefetch(o, chunk)
+
+	all := []*Event{}
+	for ty := chunk.Min.Y; ty < chunk.Max.Y; ty++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for tx := chunk.Min.X; tx < chunk.Max.X; tx++ {
+			evts, err := a.placeTile(o, tx, ty)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, evts...)
+		}
+	}
+
+	return all, nil
+}
+
+// chunkRegion splits `region` into chunks of at most size x size tiles, ordered
+// by row then column.
+func chunkRegion(region image.Rectangle, size int) []image.Rectangle {
+	chunks := []image.Rectangle{}
+	for y := region.Min.Y; y < region.Max.Y; y += size {
+		for x := region.Min.X; x < region.Max.X; x += size {
+			chunks = append(chunks, image.Rect(x, y, x+size, y+size).Intersect(region))
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,30 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+func TestSetLandParallelMatchesSetLand(t *testing.T) {
+	region := image.Rect(0, 0, 70, 70)
+	o := newTestOutline()
+
+	cfg := testConfig()
+	want := newTestMap(region.Dx(), region.Dy())
+	err := newTestAutotiler(t, cfg).SetLand(o, region, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 2, 3, 8} {
+		t.Run(fmt.Sprintf("workers-%d", workers), func(t *testing.T) {
+			got := newTestMap(region.Dx(), region.Dy())
+			err := newTestAutotiler(t, testConfig()).SetLandParallel(o, region, got, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameTiles(t, cfg, want, got, region)
+		})
+	}
+}
@@ -20,7 +20,7 @@ func FillRect(dst tile.Tileable, t *autotile.Tileset, in image.Rectangle, z int,
 	toSet := map[string]bool{} // properties will be set on these
 
 	pickTile := func(tx, ty int, in []string) error {
-		src := one(rng, in)
+		src := t.Pick(rng, in)
 		if src == "" {
 			return nil
 		}
@@ -31,6 +31,13 @@ func FillRect(dst tile.Tileable, t *autotile.Tileset, in image.Rectangle, z int,
 	for y := in.Max.Y; y >= in.Min.Y; y-- {
 		for x := in.Min.X; x <= in.Max.X; x++ {
 			var err error
+			if t.Wang != nil {
+				err = pickTile(x, y, t.Wang.Pieces[autotile.WangRectMask(in, x, y)])
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			switch y {
 			case in.Max.Y:
 				switch x {
@@ -77,11 +84,3 @@ func FillRect(dst tile.Tileable, t *autotile.Tileset, in image.Rectangle, z int,
 
 	return nil
 }
-
-// one chooses one item at random
-func one(rng *rand.Rand, items []string) string {
-	if items == nil || len(items) == 0 {
-		return ""
-	}
-	return items[rng.Intn(len(items))]
-}
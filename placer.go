@@ -0,0 +1,195 @@
+package autotile
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/voidshard/tile"
+)
+
+const (
+	// Names of the built in placement passes. Registering a Placer with one of
+	// these names replaces the built in pass.
+	PassNull     = "null"
+	PassLand     = "land"
+	PassWater    = "water"
+	PassRoad     = "road"
+	PassMolten   = "molten"
+	PassCliffs   = "cliffs"
+	PassCliffTop = "cliff-top"
+
+	// The order in which the built in passes run during SetLand. Passes are run
+	// lowest order first, so later passes are placed on top of earlier ones.
+	// Eg. a pass registered with OrderLand+1 runs after land but before water.
+	OrderNull     = 0
+	OrderLand     = 100
+	OrderWater    = 200
+	OrderRoad     = 300
+	OrderMolten   = 400
+	OrderCliffs   = 500
+	OrderCliffTop = 550
+)
+
+// Placer is a placement pass run for every tile during SetLand (and asked for
+// tags during TagsAt).
+//
+// Placers are required to be thread safe (see SetLandParallel) & should only
+// use the given `rng` for random numbers so that the same tile is chosen for a
+// given (x,y) each time.
+type Placer interface {
+	// Place returns the events (tiles) to set at the given Site & the tag that
+	// best describes the Site, or "" if this Placer doesn't apply here.
+	// If `tagsOnly` is set the events aren't required (and can be nil).
+	Place(rng *rand.Rand, s *Site, tagsOnly bool) ([]*Event, string, error)
+}
+
+// PlacerFunc allows a plain function to be used as a Placer
+type PlacerFunc func(rng *rand.Rand, s *Site, tagsOnly bool) ([]*Event, string, error)
+
+// Place calls the underlying function
+func (f PlacerFunc) Place(rng *rand.Rand, s *Site, tagsOnly bool) ([]*Event, string, error) {
+	return f(rng, s, tagsOnly)
+}
+
+// Site is a single (x,y) location being considered by a Placer, with helpers to
+// look at the land around it.
+type Site struct {
+	// X co-ord of this site
+	X int
+
+	// Y co-ord of this site
+	Y int
+
+	// Data is the LandData of this site
+	Data LandData
+
+	o   Outline
+	cfg *Config
+	me  *area
+}
+
+// newSite returns a Site for the given area
+func newSite(o Outline, cfg *Config, me *area) *Site {
+	return &Site{X: me.X, Y: me.Y, Data: me.Data, o: o, cfg: cfg, me: me}
+}
+
+// Config returns the Autotiler config, this must not be modified.
+func (s *Site) Config() *Config {
+	return s.cfg
+}
+
+// LandAt returns the LandData at (x, y) offset from this Site.
+func (s *Site) LandAt(dx, dy int) LandData {
+	return s.o.LandAt(s.X+dx, s.Y+dy)
+}
+
+// Neighbour returns the LandData of the adjacent tile in the given direction.
+func (s *Site) Neighbour(h Heading) LandData {
+	dx, dy := h.Offset()
+	if dx == 0 && dy == 0 {
+		return s.Data
+	}
+	return s.LandAt(dx, dy)
+}
+
+// Within returns how many tiles within `r` tiles of this Site (excluding the
+// Site itself) match `fn`.
+func (s *Site) Within(r int, fn func(LandData) bool) int {
+	return len(withinRadius(s.o, s.X, s.Y, r, func(in *area) bool { return fn(in.Data) }))
+}
+
+// Piece chooses which piece of the Tileset `t` to place here, given which of the
+// surrounding tiles are considered part of the same terrain by `isIn`.
+func (s *Site) Piece(rng *rand.Rand, t *Tileset, isIn func(LandData) bool) string {
+	if t == nil {
+		return ""
+	}
+	return t.choosePiece(rng, cardinals(s.o, s.X, s.Y), func(in *area) bool { return isIn(in.Data) })
+}
+
+// Event returns an event setting `src` at this Site on layer `z`.
+func (s *Site) Event(z int, src string, props *tile.Properties) *Event {
+	return newEvent(s.X, s.Y, z, src, props)
+}
+
+// builtinPlacer adapts our internal placement functions to the Placer interface
+type builtinPlacer fnPlacements
+
+// Place calls the underlying placement func
+func (f builtinPlacer) Place(rng *rand.Rand, s *Site, tagsOnly bool) ([]*Event, string, error) {
+	return f(s.o, rng, s.me, tagsOnly)
+}
+
+// placementPass is a Placer & the name we use to seed it
+type placementPass struct {
+	name   string
+	order  int
+	placer Placer
+
+	// tagRank is used to order passes during TagsAt (lowest first).
+	// User passes have a rank of -1 so their tags take precedence.
+	tagRank int
+}
+
+// defaultPasses returns our built in passes, in order
+func (a *Autotiler) defaultPasses() []*placementPass {
+	return []*placementPass{
+		{name: PassNull, order: OrderNull, placer: builtinPlacer(a.placeNull), tagRank: 0},
+		{name: PassLand, order: OrderLand, placer: builtinPlacer(a.placeLand), tagRank: 6},
+		{name: PassWater, order: OrderWater, placer: builtinPlacer(a.placeWater), tagRank: 3},
+		{name: PassRoad, order: OrderRoad, placer: builtinPlacer(a.placeRoad), tagRank: 5},
+		{name: PassMolten, order: OrderMolten, placer: builtinPlacer(a.placeMolten), tagRank: 4},
+		{name: PassCliffs, order: OrderCliffs, placer: builtinPlacer(a.placeCliffs), tagRank: 1},
+		{name: PassCliffTop, order: OrderCliffTop, placer: builtinPlacer(a.placeCliffTop), tagRank: 2},
+	}
+}
+
+// RegisterPlacer adds a placement pass that will be run for every tile during SetLand,
+// passes run in ascending `order` (see OrderLand etc). Registering a pass with an
+// existing name (including a built in pass, see PassLand etc) replaces it.
+//
+// During TagsAt tags from registered passes take precedence over the built in terrain
+// tags, where more than one registered pass reports a tag, the one with the highest
+// order wins.
+//
+// Nb. this should not be called while the Autotiler is in use.
+func (a *Autotiler) RegisterPlacer(name string, p Placer, order int) error {
+	if name == "" {
+		return fmt.Errorf("%s: placer name is required", ErrMissingRequiredValue)
+	}
+	if p == nil {
+		return fmt.Errorf("%s: placer is required", ErrMissingRequiredValue)
+	}
+
+	passes := []*placementPass{}
+	rank := -1
+	for _, pass := range a.passes {
+		if pass.name == name {
+			rank = pass.tagRank
+			continue
+		}
+		passes = append(passes, pass)
+	}
+	passes = append(passes, &placementPass{name: name, order: order, placer: p, tagRank: rank})
+
+	sort.SliceStable(passes, func(i, j int) bool { return passes[i].order < passes[j].order })
+	a.passes = passes
+
+	return nil
+}
+
+// tagPasses returns our passes in the order they should be asked for tags
+func (a *Autotiler) tagPasses() []*placementPass {
+	passes := make([]*placementPass, len(a.passes))
+	copy(passes, a.passes)
+
+	sort.SliceStable(passes, func(i, j int) bool {
+		if passes[i].tagRank != passes[j].tagRank {
+			return passes[i].tagRank < passes[j].tagRank
+		}
+		return passes[i].order > passes[j].order
+	})
+
+	return passes
+}
@@ -0,0 +1,190 @@
+package autotile
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/voidshard/tile"
+)
+
+// Plan is the set of events that SetLand / SetObjects would enact, without
+// having touched a map. Plans can be inspected, filtered & compared and then
+// applied to a map later.
+type Plan struct {
+	// Events in the order they would be enacted
+	Events []*Event
+}
+
+// PlanLand returns the Plan that SetLand would enact for the given region
+func (a *Autotiler) PlanLand(o Outline, region image.Rectangle) (*Plan, error) {
+	r := &run{region: region, plan: &Plan{Events: []*Event{}}}
+	err := a.setLand(context.Background(), o, nil, r, nil)
+	return r.plan, err
+}
+
+// PlanObjects returns the Plan that SetObjects would enact for the given region.
+// The map `t` is only read from (the bin needs to know what fits where); objects
+// chosen are tracked separately so that they're considered as we go.
+func (a *Autotiler) PlanObjects(o Outline, region image.Rectangle, t tile.Tileable, bin ObjectBin) (*Plan, error) {
+	r := &run{region: region, plan: &Plan{Events: []*Event{}}}
+	err := a.setObjects(context.Background(), newPlanTileable(t), bin, r, nil)
+	return r.plan, err
+}
+
+// Filter returns a new plan with only the events for which `fn` returns true
+func (p *Plan) Filter(fn func(*Event) bool) *Plan {
+	evts := []*Event{}
+	for _, e := range p.Events {
+		if fn(e) {
+			evts = append(evts, e)
+		}
+	}
+	return &Plan{Events: evts}
+}
+
+// Diff returns a plan that, applied to a map that this plan has been applied to,
+// results in the same map as applying `other`. That is
+// - events from `other` that set a different tile (or object) to ours
+// - events clearing (Src "") tiles we set that `other` does not
+func (p *Plan) Diff(other *Plan) *Plan {
+	mine := p.final()
+	theirs := other.final()
+
+	evts := []*Event{}
+	for _, e := range other.Events {
+		k := planKey{e.X, e.Y, e.Z, e.ObjectID != ""}
+		if theirs[k] != e {
+			continue // overwritten later in the plan
+		}
+		if m, ok := mine[k]; ok && m.Src == e.Src && m.ObjectID == e.ObjectID {
+			continue // no change
+		}
+		evts = append(evts, e)
+	}
+	for _, e := range p.Events {
+		k := planKey{e.X, e.Y, e.Z, e.ObjectID != ""}
+		if mine[k] != e || e.ObjectID != "" {
+			continue // nb. objects can't be removed
+		}
+		if _, ok := theirs[k]; ok {
+			continue
+		}
+		evts = append(evts, newEvent(e.X, e.Y, e.Z, "", nil))
+	}
+
+	return &Plan{Events: evts}
+}
+
+// Apply enacts the plan on the given map
+func (p *Plan) Apply(t tile.Tileable) error {
+	for _, e := range p.Events {
+		if e.ObjectID != "" {
+			if e.object == nil {
+				return fmt.Errorf("%s: object %s has no map to place", ErrMissingRequiredValue, e.ObjectID)
+			}
+			err := t.Add(e.X, e.Y, e.Z, e.object)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := setTile(t, e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planKey identifies what an event sets
+type planKey struct {
+	x, y, z int
+	object  bool
+}
+
+// final returns the last event for each location in the plan
+func (p *Plan) final() map[planKey]*Event {
+	last := map[planKey]*Event{}
+	for _, e := range p.Events {
+		last[planKey{e.X, e.Y, e.Z, e.ObjectID != ""}] = e
+	}
+	return last
+}
+
+// planTileable wraps a Tileable so that writes are kept in memory rather than
+// made to the underlying map, reads consider both.
+type planTileable struct {
+	t     tile.Tileable
+	tiles map[planKey]string
+}
+
+// newPlanTileable returns a planTileable on top of `t`
+func newPlanTileable(t tile.Tileable) *planTileable {
+	return &planTileable{t: t, tiles: map[planKey]string{}}
+}
+
+// Set records the tile at (x,y,z)
+func (p *planTileable) Set(x, y, z int, src string) error {
+	p.tiles[planKey{x, y, z, false}] = src
+	return nil
+}
+
+// At returns the src at (x,y,z) considering what we've recorded first
+func (p *planTileable) At(x, y, z int) (string, error) {
+	if src, ok := p.tiles[planKey{x, y, z, false}]; ok {
+		return src, nil
+	}
+	return p.t.At(x, y, z)
+}
+
+// Add records the tiles of `o` as if placed at (x,y,z)
+func (p *planTileable) Add(x, y, z int, o *tile.Map) error {
+	for _, oz := range o.ZLevels() {
+		for oy := 0; oy < o.Height; oy++ {
+			for ox := 0; ox < o.Width; ox++ {
+				src, _ := o.At(ox, oy, oz)
+				if src == "" {
+					continue
+				}
+				p.tiles[planKey{x + ox, y + oy, z + oz, false}] = src
+			}
+		}
+	}
+	return nil
+}
+
+// Fits returns if `o` fits in the underlying map & doesn't overlap anything we've recorded
+func (p *planTileable) Fits(x, y, z int, o *tile.Map) (bool, error) {
+	fits, err := p.t.Fits(x, y, z, o)
+	if err != nil || !fits {
+		return fits, err
+	}
+
+	for _, oz := range o.ZLevels() {
+		for oy := 0; oy < o.Height; oy++ {
+			for ox := 0; ox < o.Width; ox++ {
+				src, _ := o.At(ox, oy, oz)
+				if src == "" {
+					continue
+				}
+				if p.tiles[planKey{x + ox, y + oy, z + oz, false}] != "" {
+					return false, nil
+				}
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// Properties returns properties from the underlying map
+func (p *planTileable) Properties(src string) (*tile.Properties, error) {
+	return p.t.Properties(src)
+}
+
+// SetProperties does nothing, we never write to the underlying map
+func (p *planTileable) SetProperties(src string, props *tile.Properties) error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package autotile
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/tile"
+)
+
+func TestPlanLandApplyMatchesSetLand(t *testing.T) {
+	whole := image.Rect(0, 0, 60, 60)
+	cfg := testConfig()
+	o := newTestOutline()
+
+	want := newTestMap(whole.Dx(), whole.Dy())
+	err := newTestAutotiler(t, cfg).SetLand(o, whole, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := newTestAutotiler(t, cfg).PlanLand(o, whole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := newTestMap(whole.Dx(), whole.Dy())
+	err = p.Apply(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameTiles(t, cfg, want, got, whole)
+}
+
+func TestPlanDiffApply(t *testing.T) {
+	whole := image.Rect(0, 0, 60, 60)
+	cfg := testConfig()
+
+	// flatten a patch into dry, even grassland so some tiles are no longer set
+	before := newTestOutline()
+	after := newTestOutline()
+	for y := 15; y < 40; y++ {
+		for x := 10; x < 35; x++ {
+			after.changed[image.Pt(x, y)] = &testLand{height: 100, temp: 15, rain: 100}
+		}
+	}
+
+	a, err := newTestAutotiler(t, cfg).PlanLand(before, whole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newTestAutotiler(t, cfg).PlanLand(after, whole)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := newTestMap(whole.Dx(), whole.Dy())
+	err = b.Apply(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := newTestMap(whole.Dx(), whole.Dy())
+	err = a.Apply(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := a.Diff(b)
+	cleared := 0
+	for _, e := range diff.Events {
+		if e.Src == "" {
+			cleared++
+		}
+	}
+	if cleared == 0 {
+		t.Fatal("expected the diff to clear some tiles")
+	}
+	if len(diff.Events) >= len(b.Events) {
+		t.Errorf("expected the diff to be smaller than the plan, got %d events vs %d", len(diff.Events), len(b.Events))
+	}
+	err = diff.Apply(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameTiles(t, cfg, want, got, whole)
+}
+
+// rockBin places a 2x1 rock wherever it fits on every third row
+type rockBin struct{}
+
+func (rockBin) Choose(t tile.Tileable, x, y, z int) (string, *tile.Map, error) {
+	if y%3 != 0 {
+		return "", nil, nil
+	}
+	rock := tile.New(&tile.Config{TileWidth: 16, TileHeight: 16, MapWidth: 2, MapHeight: 1})
+	for rx := 0; rx < 2; rx++ {
+		err := rock.Set(rx, 0, 0, "rock")
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	fits, err := t.Fits(x, y, z, rock)
+	if err != nil || !fits {
+		return "", nil, err
+	}
+	return "rock", rock, nil
+}
+
+func TestPlanObjectsLeavesMapUntouched(t *testing.T) {
+	whole := image.Rect(0, 0, 30, 30)
+	cfg := testConfig()
+	o := newTestOutline()
+	a := newTestAutotiler(t, cfg)
+
+	m := newTestMap(whole.Dx(), whole.Dy())
+	err := a.SetLand(o, whole, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := strings.Join(dumpMap(t, cfg, m, whole), "\n")
+
+	p, err := a.PlanObjects(o, whole, m, rockBin{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Events) == 0 {
+		t.Fatal("expected some objects to be planned")
+	}
+	if after := strings.Join(dumpMap(t, cfg, m, whole), "\n"); after != before {
+		t.Fatal("PlanObjects changed the map")
+	}
+
+	// objects planned are considered when choosing the next, so rocks don't overlap
+	at := map[image.Point]bool{}
+	for _, e := range p.Events {
+		if at[image.Pt(e.X-1, e.Y)] {
+			t.Errorf("rock at (%d,%d) overlaps the one before it", e.X, e.Y)
+		}
+		at[image.Pt(e.X, e.Y)] = true
+	}
+
+	err = p.Apply(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range p.Events {
+		if e.ObjectID != "rock" {
+			t.Fatalf("expected only rocks, got %q", e.ObjectID)
+		}
+		src, err := m.At(e.X, e.Y, e.Z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src != "rock" {
+			t.Errorf("expected rock at (%d,%d,%d) got %q", e.X, e.Y, e.Z, src)
+		}
+	}
+}
@@ -6,7 +6,8 @@ import (
 
 const (
 	// relative z levels of objects (higher is .. higher)
-	zoffsetObject    = 6
+	zoffsetObject    = 7
+	zoffsetSteam     = 6
 	zoffsetWaterfall = 5
 	zoffsetCliff     = 4
 	zoffsetRoad      = 3
@@ -18,16 +19,29 @@ const (
 	pWall   = "wall"
 	pWater  = "water"
 	pLava   = "lava"
+
+	// pAnimation holds the frames of animated tiles (see Tileset.Animations)
+	pAnimation = "animation"
 )
 
 var (
-	propertiesWater *tile.Properties = nil
-	propertiesCliff *tile.Properties = nil
-	propertiesWFall *tile.Properties = nil
-	propertiesLand  *tile.Properties = nil
-	propertiesRoad  *tile.Properties = nil
-	propertiesLava  *tile.Properties = nil
-	propertiesNull  *tile.Properties = nil
+	// propertyKeys are all of the property names we set, since tile.Properties
+	// can't be iterated these are the properties we know to look for
+	propertyKeys = []string{pObject, pWall, pWater, pLava, pAnimation}
+
+	propertiesWater     *tile.Properties = nil
+	propertiesIce       *tile.Properties = nil
+	propertiesDeepWater *tile.Properties = nil
+	propertiesCliff     *tile.Properties = nil
+	propertiesCliffTop  *tile.Properties = nil
+	propertiesWFall     *tile.Properties = nil
+	propertiesLand      *tile.Properties = nil
+	propertiesRoad      *tile.Properties = nil
+	propertiesDock      *tile.Properties = nil
+	propertiesLava      *tile.Properties = nil
+	propertiesObsidian  *tile.Properties = nil
+	propertiesSteam     *tile.Properties = nil
+	propertiesNull      *tile.Properties = nil
 )
 
 func init() {
@@ -38,16 +52,36 @@ func init() {
 	propertiesWater.SetString(pObject, "water")
 	propertiesWater.SetBool(pWater, true)
 
+	propertiesDeepWater = tile.NewProperties()
+	propertiesDeepWater.SetString(pObject, "deep-water")
+	propertiesDeepWater.SetBool(pWater, true)
+
+	propertiesIce = tile.NewProperties()
+	propertiesIce.SetString(pObject, "ice")
+
 	propertiesLand = tile.NewProperties()
 	propertiesLand.SetString(pObject, "land")
 
 	propertiesRoad = tile.NewProperties()
 	propertiesRoad.SetString(pObject, "road")
 
+	propertiesDock = tile.NewProperties()
+	propertiesDock.SetString(pObject, "dock")
+
 	propertiesCliff = tile.NewProperties()
 	propertiesCliff.SetString(pObject, "cliff-face")
 	propertiesCliff.SetBool(pWall, true)
 
+	propertiesCliffTop = tile.NewProperties()
+	propertiesCliffTop.SetString(pObject, "cliff-edge")
+
+	propertiesObsidian = tile.NewProperties()
+	propertiesObsidian.SetString(pObject, "obsidian")
+
+	propertiesSteam = tile.NewProperties()
+	propertiesSteam.SetString(pObject, "steam")
+	propertiesSteam.SetBool(pWater, true)
+
 	propertiesLava = tile.NewProperties()
 	propertiesLava.SetString(pObject, "lava")
 	propertiesLava.SetBool(pLava, true)
@@ -0,0 +1,57 @@
+package autotile
+
+import (
+	"math/rand"
+)
+
+// hashSource is a small & cheap to create rand.Source (splitmix64).
+// We make one of these per tile per placement pass so they need to be
+// fast to seed (unlike the default math/rand source).
+type hashSource struct {
+	state uint64
+}
+
+// Seed sets our internal state
+func (h *hashSource) Seed(seed int64) {
+	h.state = uint64(seed)
+}
+
+// Uint64 returns the next pseudo random number
+func (h *hashSource) Uint64() uint64 {
+	h.state += 0x9e3779b97f4a7c15
+	return mix64(h.state)
+}
+
+// Int63 returns the next pseudo random number as a non-negative int64
+func (h *hashSource) Int63() int64 {
+	return int64(h.Uint64() >> 1)
+}
+
+// mix64 is the splitmix64 finaliser, it scrambles the bits of `z`
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// tileSeed hashes (seed, x, y, pass) into a single seed value.
+func tileSeed(seed int64, x, y int, pass string) int64 {
+	// FNV-1a of the pass name
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(pass); i++ {
+		h ^= uint64(pass[i])
+		h *= 1099511628211
+	}
+
+	h = mix64(h ^ uint64(seed))
+	h = mix64(h ^ uint64(int64(x)))
+	h = mix64(h ^ uint64(int64(y)))
+	return int64(h)
+}
+
+// tileRand returns a random number generator for the given tile & pass.
+// The numbers we get depend only on the inputs, so the same tile is always
+// given the same numbers no matter what region it is placed as part of.
+func tileRand(seed int64, x, y int, pass string) *rand.Rand {
+	return rand.New(&hashSource{state: uint64(tileSeed(seed, x, y, pass))})
+}
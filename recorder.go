@@ -0,0 +1,166 @@
+package autotile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/voidshard/tile"
+)
+
+// eventRecord is the JSON form of an Event
+type eventRecord struct {
+	X          int                    `json:"x"`
+	Y          int                    `json:"y"`
+	Z          int                    `json:"z"`
+	Src        string                 `json:"src,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	ObjectID   string                 `json:"object,omitempty"`
+}
+
+// EventRecorder writes events as JSON, one event per line (JSON Lines).
+// The result can be given to Replay to rebuild the same map without the
+// original Outline.
+//
+// Nb. tile properties can't be listed, so only the properties the autotiler
+// sets are recorded.
+type EventRecorder struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+// NewEventRecorder returns a recorder that writes to `w`
+func NewEventRecorder(w io.Writer) *EventRecorder {
+	return &EventRecorder{enc: json.NewEncoder(w)}
+}
+
+// Record writes a single event. Events that don't place anything (ie. Done) are skipped.
+func (r *EventRecorder) Record(e *Event) error {
+	if e == nil || e.Done {
+		return nil
+	}
+
+	rec := &eventRecord{X: e.X, Y: e.Y, Z: e.Z, Src: e.Src, ObjectID: e.ObjectID}
+	if e.Properties != nil {
+		rec.Properties = map[string]interface{}{}
+		for _, k := range propertyKeys {
+			if v, ok := e.Properties.String(k); ok {
+				rec.Properties[k] = v
+			} else if v, ok := e.Properties.Int(k); ok {
+				rec.Properties[k] = v
+			} else if v, ok := e.Properties.Bool(k); ok {
+				rec.Properties[k] = v
+			}
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.enc.Encode(rec)
+}
+
+// Consume records all events from the given channel (see Subscribe) until
+// it's closed.
+func (r *EventRecorder) Consume(events <-chan *Event) error {
+	var final error
+	for e := range events {
+		err := r.Record(e)
+		if err != nil && final == nil {
+			final = err // keep reading so we don't block the autotiler
+		}
+	}
+	return final
+}
+
+// Replay reads events written by an EventRecorder & sets the same tiles on `t`.
+// Recordings that include objects require a Loader, see ReplayWithLoader.
+func Replay(r io.Reader, t tile.Tileable) error {
+	return ReplayWithLoader(r, t, nil)
+}
+
+// ReplayWithLoader is Replay but objects are also placed, fetched from the loader by their ID.
+func ReplayWithLoader(r io.Reader, t tile.Tileable, ldr Loader) error {
+	objects := map[string]*tile.Map{}
+
+	dec := json.NewDecoder(r)
+	for {
+		rec := &eventRecord{}
+		err := dec.Decode(rec)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if rec.ObjectID != "" {
+			if ldr == nil {
+				return fmt.Errorf("%s: loader required to replay object %s", ErrMissingRequiredValue, rec.ObjectID)
+			}
+			obj, ok := objects[rec.ObjectID]
+			if !ok {
+				obj, err = ldr.Map(rec.ObjectID)
+				if err != nil {
+					return err
+				}
+				objects[rec.ObjectID] = obj
+			}
+			err = t.Add(rec.X, rec.Y, rec.Z, obj)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if rec.Src == "" || rec.Properties == nil {
+			err = t.Set(rec.X, rec.Y, rec.Z, rec.Src)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		e, err := rec.event()
+		if err != nil {
+			return err
+		}
+		err = setTile(t, e) // nb. this adds any animation frames to the tileset
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// event returns the Event (with any animation) for a recorded tile
+func (rec *eventRecord) event() (*Event, error) {
+	props := tile.NewProperties()
+	frameProps := tile.NewProperties() // animation frames have all but the animation
+	var frames []Frame
+	for k, v := range rec.Properties {
+		switch value := v.(type) {
+		case string:
+			props.SetString(k, value)
+			if k == pAnimation {
+				var err error
+				frames, err = parseAnimation(value)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				frameProps.SetString(k, value)
+			}
+		case bool:
+			props.SetBool(k, value)
+			frameProps.SetBool(k, value)
+		case float64: // all JSON numbers decode as float64
+			props.SetInt(k, int(value))
+			frameProps.SetInt(k, int(value))
+		}
+	}
+
+	e := newEvent(rec.X, rec.Y, rec.Z, rec.Src, props)
+	if len(frames) > 0 {
+		e.anim = &animation{frames: frames, props: frameProps}
+	}
+	return e, nil
+}
@@ -0,0 +1,299 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/voidshard/tile"
+)
+
+// everywhere is a region that covers all valid co-ords
+var everywhere = image.Rect(math.MinInt32, math.MinInt32, math.MaxInt32, math.MaxInt32)
+
+// Retile re-places land tiles after the Outline has changed at the given locations.
+// Only tiles whose choice could depend on the changed locations are considered
+// (neighbours, beaches, cliffs & features like waterfalls or stairs), stale tiles
+// are cleared & new ones set. Events are sent for just the tiles that changed;
+// a tile being cleared is reported with an empty Src.
+//
+// Tiles are only changed within the bounds of `t`, which must be a *tile.Map or
+// *tile.InfiniteMap (which has no bounds), for other maps use RetileRegion.
+func (a *Autotiler) Retile(o Outline, t tile.Tileable, changed []image.Point) error {
+	region, err := tileableBounds(t)
+	if err != nil {
+		return err
+	}
+	return a.RetileRegion(o, region, t, changed)
+}
+
+// tileableBounds returns the area of `t` that tiles can be set in
+func tileableBounds(t tile.Tileable) (image.Rectangle, error) {
+	switch m := t.(type) {
+	case *tile.Map:
+		return image.Rect(0, 0, m.Width, m.Height), nil
+	case *tile.InfiniteMap:
+		return everywhere, nil
+	}
+	return image.Rectangle{}, fmt.Errorf("%s: bounds of %T are unknown, use RetileRegion", ErrMissingRequiredValue, t)
+}
+
+// RetileRegion is Retile but tiles outside of `region` are never changed.
+func (a *Autotiler) RetileRegion(o Outline, region image.Rectangle, t tile.Tileable, changed []image.Point) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// tiles that look this far from themselves may be affected by a change, and
+	// feature tiles (waterfalls, stairs ..) can be this far again from the tiles
+	// that decide them .. so stale feature tiles are within `radius` of a change.
+	radius := a.windowPadding() + a.collisionMargin() + 1
+
+	groups := groupChanges(changed, radius)
+
+	bounds := groups[0].bounds
+	for _, g := range groups[1:] {
+		bounds = bounds.Union(g.bounds)
+	}
+
+	r := a.newRun(bounds)
+	defer a.finish(r)
+
+	for _, g := range groups {
+		err := a.retileGroup(o, region, t, r, g, radius)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changeGroup is a set of changes close enough to be re-tiled together
+type changeGroup struct {
+	changes []image.Point
+	bounds  image.Rectangle
+}
+
+// groupChanges groups changes whose areas (`radius` tiles around each change)
+// overlap, so far apart changes are re-tiled separately.
+func groupChanges(changed []image.Point, radius int) []*changeGroup {
+	groups := []*changeGroup{}
+	for _, c := range changed {
+		groups = append(groups, &changeGroup{
+			changes: []image.Point{c},
+			bounds:  image.Rect(c.X-radius, c.Y-radius, c.X+radius+1, c.Y+radius+1),
+		})
+	}
+
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(groups) && !merged; i++ {
+			for j := i + 1; j < len(groups); j++ {
+				if !groups[i].bounds.Overlaps(groups[j].bounds) {
+					continue
+				}
+				groups[i].changes = append(groups[i].changes, groups[j].changes...)
+				groups[i].bounds = groups[i].bounds.Union(groups[j].bounds)
+				groups = append(groups[:j], groups[j+1:]...)
+				merged = true
+				break
+			}
+		}
+	}
+
+	return groups
+}
+
+// retileGroup re-tiles around a single group of changes
+func (a *Autotiler) retileGroup(o Outline, region image.Rectangle, t tile.Tileable, r *run, g *changeGroup, radius int) error {
+	reach := a.collisionMargin() + 1
+
+	dirty := map[image.Point]bool{}
+	for _, c := range g.changes {
+		for y := c.Y - radius; y <= c.Y+radius; y++ {
+			for x := c.X - radius; x <= c.X+radius; x++ {
+				dirty[image.Pt(x, y)] = true
+			}
+		}
+	}
+
+	// we look for features that could reach the dirty tiles
+	area := g.bounds.Inset(-reach)
+	o = a.prefetch(o, area.Inset(-reach))
+
+	collisions := newCollisionHandler()
+	land := map[image.Point][]*Event{}
+	placeAt := func(x, y int, detect bool) error {
+		evts, err := a.placeTile(o, x, y)
+		if err != nil {
+			return err
+		}
+		found := []*Event{}
+		for _, e := range evts {
+			if e.collisionType == "" {
+				found = append(found, e)
+			} else if detect {
+				collisions.append(e)
+			}
+		}
+		land[image.Pt(x, y)] = found
+		return nil
+	}
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			err := placeAt(x, y, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// a feature touching the dirty tiles is placed in full, over the whole
+	// rect it covers (so any of it's stale tiles are cleared)
+	type resolved struct {
+		evts  []*Event
+		rect  image.Rectangle
+		added bool
+	}
+	features := []*resolved{}
+	for _, col := range collisions.All() {
+		evts, err := a.handleCollision(o, col)
+		if err != nil {
+			return err
+		}
+		rect := col.bounds()
+		for _, e := range evts {
+			rect = rect.Union(image.Rect(e.X, e.Y, e.X+1, e.Y+1))
+		}
+		features = append(features, &resolved{evts: evts, rect: rect})
+	}
+
+	placed := []*Event{}
+	for grew := true; grew; {
+		grew = false
+		for _, f := range features {
+			if f.added || !touches(f.rect, dirty) {
+				continue
+			}
+			f.added = true
+			grew = true
+			placed = append(placed, f.evts...)
+			for y := f.rect.Min.Y; y < f.rect.Max.Y; y++ {
+				for x := f.rect.Min.X; x < f.rect.Max.X; x++ {
+					dirty[image.Pt(x, y)] = true
+				}
+			}
+		}
+	}
+
+	// the last event for a given tile wins, as it would during SetLand
+	want := map[[3]int]*Event{}
+	layers := a.landLayers()
+	pnts := []image.Point{}
+	for p := range dirty {
+		if !p.In(region) {
+			continue
+		}
+		pnts = append(pnts, p)
+		if _, ok := land[p]; !ok {
+			err := placeAt(p.X, p.Y, false) // a feature reached beyond our area
+			if err != nil {
+				return err
+			}
+		}
+		for _, e := range land[p] {
+			want[[3]int{e.X, e.Y, e.Z}] = e
+			layers[e.Z] = true
+		}
+	}
+	for _, e := range placed {
+		want[[3]int{e.X, e.Y, e.Z}] = e
+		layers[e.Z] = true
+	}
+
+	zs := []int{}
+	for z := range layers {
+		zs = append(zs, z)
+	}
+	sort.Ints(zs)
+
+	sort.Slice(pnts, func(i, j int) bool {
+		if pnts[i].Y != pnts[j].Y {
+			return pnts[i].Y < pnts[j].Y
+		}
+		return pnts[i].X < pnts[j].X
+	})
+
+	// finally write out the differences
+	for _, p := range pnts {
+		for _, z := range zs {
+			old, err := t.At(p.X, p.Y, z)
+			if err != nil {
+				return err
+			}
+
+			e, ok := want[[3]int{p.X, p.Y, z}]
+			if !ok {
+				e = newEvent(p.X, p.Y, z, "", nil)
+			}
+			if old == e.Src {
+				continue
+			}
+
+			err = setTile(t, e)
+			if err != nil {
+				return err
+			}
+
+			a.emit(r, e)
+		}
+	}
+
+	return nil
+}
+
+// touches returns if any tile in `r` is dirty
+func touches(r image.Rectangle, dirty map[image.Point]bool) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if dirty[image.Pt(x, y)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// landLayers returns all of the layers land tiles may be placed on; the built in
+// layers & any layer we've seen a Placer or Feature set a tile on.
+func (a *Autotiler) landLayers() map[int]bool {
+	layers := map[int]bool{
+		a.cfg.ZOffsetLand:      true,
+		a.cfg.ZOffsetLand + 1:  true, // transitions
+		a.cfg.ZOffsetWater:     true,
+		a.cfg.ZOffsetRoad:      true,
+		a.cfg.ZOffsetCliff:     true,
+		a.cfg.ZOffsetWaterfall: true,
+		a.cfg.ZOffsetSteam:     true,
+	}
+	a.layers.Range(func(k, _ interface{}) bool {
+		layers[k.(int)] = true
+		return true
+	})
+	return layers
+}
+
+// seenLayers records the layers the given events set tiles on
+func (a *Autotiler) seenLayers(evts []*Event) {
+	for _, e := range evts {
+		if e.collisionType != "" || e.Src == "" {
+			continue
+		}
+		if _, ok := a.layers.Load(e.Z); !ok {
+			a.layers.Store(e.Z, true)
+		}
+	}
+}
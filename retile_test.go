@@ -0,0 +1,141 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/voidshard/tile"
+)
+
+func TestRetileMatchesSetLand(t *testing.T) {
+	whole := image.Rect(0, 0, 80, 80)
+
+	lake := func(x, y int) *testLand { return &testLand{height: 40, temp: 15, rain: 100, water: true} }
+	hill := func(x, y int) *testLand { return &testLand{height: 250, temp: 15, rain: 100} }
+	road := func(x, y int) *testLand { return &testLand{height: 130, temp: 15, rain: 100, road: true} }
+	lava := func(x, y int) *testLand { return &testLand{height: 60, temp: 30, rain: 10, molten: true} }
+
+	square := func(x0, y0, x1, y1 int, fn func(x, y int) *testLand) map[image.Point]*testLand {
+		changes := map[image.Point]*testLand{}
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				changes[image.Pt(x, y)] = fn(x, y)
+			}
+		}
+		return changes
+	}
+	merge := func(in ...map[image.Point]*testLand) map[image.Point]*testLand {
+		changes := map[image.Point]*testLand{}
+		for _, c := range in {
+			for p, l := range c {
+				changes[p] = l
+			}
+		}
+		return changes
+	}
+
+	cases := map[string]map[image.Point]*testLand{
+		"lake":        square(30, 30, 36, 35, lake),
+		"single tile": square(41, 22, 42, 23, lake),
+		"hill":        square(20, 50, 26, 56, hill),
+		"road":        square(10, 40, 60, 41, road),
+		"lava":        square(44, 44, 48, 47, lava),
+		"map edge":    square(0, 0, 4, 3, hill),
+		"far apart":   merge(square(2, 70, 6, 75, lake), square(72, 4, 76, 8, hill)),
+	}
+
+	for _, ramps := range []bool{true, false} {
+		for name, changes := range cases {
+			t.Run(fmt.Sprintf("%s-ramps-%v", name, ramps), func(t *testing.T) {
+				cfg := testConfig()
+				cfg.PreferRamps = ramps
+				a := newTestAutotiler(t, cfg)
+
+				o := newTestOutline()
+				got := newTestMap(whole.Dx(), whole.Dy())
+				err := a.SetLand(o, whole, got)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				changed := []image.Point{}
+				for p, l := range changes {
+					o.changed[p] = l
+					changed = append(changed, p)
+				}
+				err = a.Retile(o, got, changed)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := newTestMap(whole.Dx(), whole.Dy())
+				err = newTestAutotiler(t, cfg).SetLand(o, whole, want)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assertSameTiles(t, cfg, want, got, whole)
+			})
+		}
+	}
+}
+
+func TestRetileRegion(t *testing.T) {
+	whole := image.Rect(0, 0, 60, 60)
+	region := image.Rect(20, 20, 40, 40)
+	cfg := testConfig()
+	a := newTestAutotiler(t, cfg)
+
+	o := newTestOutline()
+	got := newTestMap(whole.Dx(), whole.Dy())
+	err := a.SetLand(o, whole, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := newTestMap(whole.Dx(), whole.Dy())
+	err = a.SetLand(o, whole, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := []image.Point{}
+	for y := 15; y < 25; y++ {
+		for x := 15; x < 25; x++ {
+			o.changed[image.Pt(x, y)] = &testLand{height: 40, temp: 15, rain: 100, water: true}
+			changed = append(changed, image.Pt(x, y))
+		}
+	}
+	err = a.RetileRegion(o, region, got, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := newTestMap(whole.Dx(), whole.Dy())
+	err = newTestAutotiler(t, cfg).SetLand(o, whole, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inside of the region we match a fresh SetLand, outside nothing changed
+	assertSameTiles(t, cfg, want, got, region)
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 60, 20), image.Rect(0, 40, 60, 60),
+		image.Rect(0, 20, 20, 40), image.Rect(40, 20, 60, 40),
+	} {
+		assertSameTiles(t, cfg, before, got, r)
+	}
+}
+
+// unboundedMap is a tile.Tileable whose bounds we can't know
+type unboundedMap struct {
+	*tile.Map
+}
+
+func TestRetileUnknownBounds(t *testing.T) {
+	a := newTestAutotiler(t, testConfig())
+	err := a.Retile(newTestOutline(), unboundedMap{newTestMap(10, 10)}, []image.Point{image.Pt(1, 1)})
+	if err == nil {
+		t.Error("expected an error retiling a map with unknown bounds")
+	}
+}
@@ -0,0 +1,134 @@
+package autotile
+
+import (
+	"image"
+)
+
+// distanceField holds the distance (in tiles) from each tile in some rectangle to the
+// nearest tile of some kind (eg. water). Distance here is the number of steps in any
+// of the 8 compass directions, so a tile diagonally adjacent to water is distance 1
+// (as withinRadius).
+// Matching tiles themselves are distance 0 & distances are capped at max+1.
+type distanceField struct {
+	bounds image.Rectangle
+	valid  image.Rectangle
+	max    int
+	dist   []int
+}
+
+// newDistanceField computes the distance to the nearest tile matching `fn` for all
+// tiles in `bounds`.
+// Only tiles within `bounds` are considered, so we only answer for tiles at least
+// `max` tiles from the edge (where the distance is known to be correct).
+func newDistanceField(o Outline, bounds image.Rectangle, max int, fn func(LandData) bool) *distanceField {
+	w, h := bounds.Dx(), bounds.Dy()
+	far := max + 1
+
+	dist := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if fn(o.LandAt(bounds.Min.X+x, bounds.Min.Y+y)) {
+				dist[y*w+x] = 0
+			} else {
+				dist[y*w+x] = far
+			}
+		}
+	}
+
+	// two pass chamfer transform; with all 8 neighbours at a cost of 1 this
+	// gives the exact distance in steps
+	relax := func(x, y, dx, dy int) {
+		nx, ny := x+dx, y+dy
+		if nx < 0 || nx >= w || ny < 0 || ny >= h {
+			return
+		}
+		if d := dist[ny*w+nx] + 1; d < dist[y*w+x] {
+			dist[y*w+x] = d
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			relax(x, y, -1, -1)
+			relax(x, y, 0, -1)
+			relax(x, y, 1, -1)
+			relax(x, y, -1, 0)
+		}
+	}
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			relax(x, y, 1, 1)
+			relax(x, y, 0, 1)
+			relax(x, y, -1, 1)
+			relax(x, y, 1, 0)
+		}
+	}
+
+	return &distanceField{bounds: bounds, valid: bounds.Inset(max), max: max, dist: dist}
+}
+
+// at returns the distance from (x, y) and if we know it
+func (f *distanceField) at(x, y int) (int, bool) {
+	if !image.Pt(x, y).In(f.valid) {
+		return 0, false
+	}
+	return f.dist[(y-f.bounds.Min.Y)*f.bounds.Dx()+(x-f.bounds.Min.X)], true
+}
+
+// isWater returns if the land is water
+func isWater(d LandData) bool {
+	return d.IsWater()
+}
+
+// isDry returns if the land is not water
+func isDry(d LandData) bool {
+	return !d.IsWater()
+}
+
+// shoreDistance returns the distance in tiles from (x, y) to the nearest water
+// tile, up to `max` (we return max+1 if there is no water that close).
+// If `o` is a window that has been prefetched we use it's distance field, otherwise
+// we search outward from (x, y).
+func shoreDistance(o Outline, x, y, max int) int {
+	var f *distanceField
+	if w, ok := o.(*window); ok {
+		f = w.shore()
+	}
+	return distanceTo(o, f, x, y, max, isWater)
+}
+
+// landDistance returns the distance in tiles from (x, y) to the nearest tile that
+// isn't water, up to `max` (as shoreDistance).
+func landDistance(o Outline, x, y, max int) int {
+	var f *distanceField
+	if w, ok := o.(*window); ok {
+		f = w.land()
+	}
+	return distanceTo(o, f, x, y, max, isDry)
+}
+
+// distanceTo returns the distance in tiles from (x, y) to the nearest tile matching
+// `fn` up to `max`, using the field `f` if given & it can answer.
+func distanceTo(o Outline, f *distanceField, x, y, max int, fn func(LandData) bool) int {
+	if f != nil {
+		if d, ok := f.at(x, y); ok && max <= f.max {
+			if d > max {
+				return max + 1
+			}
+			return d
+		}
+	}
+
+	if fn(o.LandAt(x, y)) {
+		return 0
+	}
+	for r := 1; r <= max; r++ {
+		// we only need to check the ring at distance r
+		for i := -r; i <= r; i++ {
+			if fn(o.LandAt(x+i, y-r)) || fn(o.LandAt(x+i, y+r)) ||
+				fn(o.LandAt(x-r, y+i)) || fn(o.LandAt(x+r, y+i)) {
+				return r
+			}
+		}
+	}
+	return max + 1
+}
@@ -4,6 +4,14 @@ const (
 	// Water represents *any* water tile
 	Water string = "water"
 
+	// Kinds of water, if we've been told how to tell them apart (see Config.SeaLevel).
+	// Tiles with these tags are also given the Water tag.
+	ShallowWater string = "shallow-water"
+	DeepWater    string = "deep-water"
+
+	// Ice is frozen water, also given the Water tag
+	Ice string = "ice"
+
 	// Specific kinds of ground
 	Grass string = "grass"
 	Sand  string = "sand"
@@ -11,12 +19,29 @@ const (
 	Snow  string = "snow"
 	Rock  string = "rock"
 
+	// Ground types decided by rainfall
+	Swamp    string = "swamp"
+	Mud      string = "mud"
+	DryGrass string = "dry-grass"
+
 	// Other misc options
 	Road      string = "road"
+	Dock      string = "dock"
 	Lava      string = "lava"
+	Obsidian  string = "obsidian"
+	Steam     string = "steam"
 	CliffFace string = "cliff-face"
 	CliffEdge string = "cliff-edge"
 
 	// we placed nothing
 	Null string = "null"
 )
+
+// parentTags maps more specific tags to the general tag they imply, both are
+// returned by TagsAt
+var parentTags = map[string]string{
+	ShallowWater: Water,
+	DeepWater:    Water,
+	Ice:          Water,
+	Steam:        Water,
+}
@@ -88,14 +88,20 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	defer at.Close()
+
+	// autotiler outputs events about what it is placing where as it goes,
+	// if you Subscribe() you *must* consume them (or cancel).
+	// Events are not stored, if you're not listening when the event occurs
+	// then you miss out
+	events, cancel := at.Subscribe(100)
+	defer cancel()
 
 	go func() {
-		// autotiler outputs events about what it is placing where as it goes,
-		// if you call Events() you *must* consume them.
-		// Events are not stored, if you're not listening when the event occurs
-		// then you miss out
-		for e := range at.Events() {
-			if e.ObjectID != "" {
+		for e := range events {
+			if e.Done {
+				log.Printf(" run %d finished for region %v\n", e.Run, e.Region)
+			} else if e.ObjectID != "" {
 				log.Printf(" (%d,%d,%d) -> placed object %s\n", e.X, e.Y, e.Z, e.ObjectID)
 			} else {
 				log.Printf(" (%d,%d,%d) -> placed tile %s\n", e.X, e.Y, e.Z, e.Src)
@@ -132,7 +138,7 @@ func main() {
 		panic(err)
 	}
 
-	err = tmap.WriteFile("maptest.01.tmx")
+	err = autotile.WriteTMXFile(tmap, "maptest.01.tmx")
 	if err != nil {
 		panic(err)
 	}
@@ -31,7 +31,7 @@ func firstTransition(rng *rand.Rand, lnd ...*Tileset) string {
 		if len(l.Transition) == 0 {
 			continue
 		}
-		return one(rng, l.Transition)
+		return l.Pick(rng, l.Transition)
 	}
 	return ""
 }
@@ -42,10 +42,11 @@ func firstFull(rng *rand.Rand, lnd ...*Tileset) string {
 		if l == nil {
 			continue
 		}
-		if len(l.Full) == 0 {
+		full := l.full()
+		if len(full) == 0 {
 			continue
 		}
-		return one(rng, l.Full)
+		return l.Pick(rng, full)
 	}
 	return ""
 }
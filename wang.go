@@ -0,0 +1,98 @@
+package autotile
+
+import (
+	"image"
+	"math/rand"
+)
+
+const (
+	// Corner bits of a WangCornerTileset mask, a bit is set if that corner of the
+	// tile is inside (of the same terrain).
+	WangNorthEast = 1
+	WangSouthEast = 2
+	WangSouthWest = 4
+	WangNorthWest = 8
+)
+
+// WangCornerTileset is a corner based (or "dual grid") tileset of 16 pieces,
+// indexed by which corners of the tile are inside (see WangNorthEast etc).
+// Ie. Pieces[WangNorthEast|WangNorthWest] is a tile whose North half is inside
+// & Pieces[15] is a full tile.
+//
+// A corner is inside if the tile and all three tiles that share that corner are
+// of the same terrain, except that runs one tile wide (eg. a single road) are
+// treated as strips, edged only at their ends.
+// Nb. corner pieces can't show where one tile wide runs meet (junctions, corners
+// of a path), these tiles have no corners inside & get Pieces[0]. Paths like this
+// are better drawn with a Tileset.Blob.
+type WangCornerTileset struct {
+	Pieces [16][]string
+
+	// Weights optionally makes some pieces more (or less) likely to be chosen,
+	// see Tileset.Weights.
+	Weights map[string]float64
+}
+
+// Piece returns one of the pieces for the given corner mask, honouring our Weights
+func (w *WangCornerTileset) Piece(rng *rand.Rand, mask int) string {
+	return w.Tileset().Pick(rng, w.Pieces[mask&15])
+}
+
+// Tileset returns a Tileset that uses this WangCornerTileset, so it can be used
+// anywhere a Tileset can be (eg. LandTiles).
+func (w *WangCornerTileset) Tileset() *Tileset {
+	return &Tileset{Wang: w, Weights: w.Weights}
+}
+
+// WangMask returns the corner mask for a tile, given if the tile itself (the centre)
+// is inside & which adjacent tiles are inside. A tile that isn't inside has no
+// corners inside.
+func WangMask(centre bool, isIn func(Heading) bool) int {
+	if !centre {
+		return 0
+	}
+	mask := 0
+	if isIn(North) && isIn(NorthEast) && isIn(East) {
+		mask |= WangNorthEast
+	}
+	if isIn(East) && isIn(SouthEast) && isIn(South) {
+		mask |= WangSouthEast
+	}
+	if isIn(South) && isIn(SouthWest) && isIn(West) {
+		mask |= WangSouthWest
+	}
+	if isIn(West) && isIn(NorthWest) && isIn(North) {
+		mask |= WangNorthWest
+	}
+	return mask
+}
+
+// WangRectMask returns the corner mask for the tile (x, y) when filling the
+// rectangle `in` (including it's max) with a single terrain.
+// A rectangle one tile wide (or high) is treated as a strip, edged only at it's
+// ends, since corner pieces can't show a terrain one tile across.
+func WangRectMask(in image.Rectangle, x, y int) int {
+	inside := image.Rect(in.Min.X, in.Min.Y, in.Max.X+1, in.Max.Y+1)
+	if !image.Pt(x, y).In(inside) {
+		return 0
+	}
+	return wangStripMask(func(dx, dy int) bool { return image.Pt(x+dx, y+dy).In(inside) })
+}
+
+// wangStripMask returns the corner mask for a tile that is inside, given if the
+// tile at each offset (dx, dy) from it is inside. A run of tiles one wide (or high)
+// is treated as a strip, edged only at it's ends.
+func wangStripMask(isIn func(dx, dy int) bool) int {
+	thinX := !isIn(-1, 0) && !isIn(1, 0)
+	thinY := !isIn(0, -1) && !isIn(0, 1)
+	return WangMask(true, func(h Heading) bool {
+		dx, dy := h.Offset()
+		if thinX {
+			dx = 0
+		}
+		if thinY {
+			dy = 0
+		}
+		return (dx == 0 && dy == 0) || isIn(dx, dy)
+	})
+}
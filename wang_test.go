@@ -0,0 +1,90 @@
+package autotile
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestWangRectMask(t *testing.T) {
+	cases := []struct {
+		name string
+		in   image.Rectangle
+		x, y int
+		want int
+	}{
+		{"outside", image.Rect(0, 0, 2, 2), 5, 5, 0},
+		{"middle", image.Rect(0, 0, 2, 2), 1, 1, 15},
+		{"north west corner", image.Rect(0, 0, 2, 2), 0, 0, WangSouthEast},
+		{"south edge", image.Rect(0, 0, 2, 2), 1, 2, WangNorthEast | WangNorthWest},
+		{"one wide, north end", image.Rect(3, 0, 3, 2), 3, 0, WangSouthEast | WangSouthWest},
+		{"one wide, middle", image.Rect(3, 0, 3, 2), 3, 1, 15},
+		{"one wide, south end", image.Rect(3, 0, 3, 2), 3, 2, WangNorthEast | WangNorthWest},
+		{"one high, west end", image.Rect(0, 3, 2, 3), 0, 3, WangNorthEast | WangSouthEast},
+		{"single tile", image.Rect(3, 3, 3, 3), 3, 3, 15},
+	}
+
+	for _, c := range cases {
+		got := WangRectMask(c.in, c.x, c.y)
+		if got != c.want {
+			t.Errorf("%s: expected mask %d got %d", c.name, c.want, got)
+		}
+	}
+}
+
+func TestWangMaskCentre(t *testing.T) {
+	all := func(Heading) bool { return true }
+	if m := WangMask(false, all); m != 0 {
+		t.Errorf("expected no corners when the centre is outside, got %d", m)
+	}
+	if m := WangMask(true, all); m != 15 {
+		t.Errorf("expected all corners, got %d", m)
+	}
+}
+
+func TestWangPieceWeights(t *testing.T) {
+	w := &WangCornerTileset{Weights: map[string]float64{"never": 0}}
+	w.Pieces[15] = []string{"never", "always"}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if p := w.Piece(rng, 15); p != "always" {
+			t.Fatalf("expected weighted piece 'always' got %s", p)
+		}
+	}
+}
+
+func TestWangChoosePieceStrip(t *testing.T) {
+	w := &WangCornerTileset{}
+	for i := range w.Pieces {
+		w.Pieces[i] = []string{fmt.Sprintf("wang.%d", i)}
+	}
+	ts := w.Tileset()
+
+	// a road one tile wide, from y = 2 to 9
+	o := newTestOutline()
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 6; x++ {
+			o.changed[image.Pt(x, y)] = &testLand{road: x == 3 && y >= 2 && y <= 9}
+		}
+	}
+	isIn := func(in *area) bool { return in.Data.IsRoad() }
+	rng := rand.New(rand.NewSource(1))
+
+	cases := []struct {
+		y    int
+		want int
+	}{
+		{2, WangSouthEast | WangSouthWest}, // the North end of the road
+		{3, 15},
+		{5, 15},
+		{9, WangNorthEast | WangNorthWest},
+	}
+	for _, c := range cases {
+		got := ts.choosePiece(rng, cardinals(o, 3, c.y), isIn)
+		if want := fmt.Sprintf("wang.%d", c.want); got != want {
+			t.Errorf("expected %s at y %d, got %s", want, c.y, got)
+		}
+	}
+}
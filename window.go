@@ -0,0 +1,116 @@
+package autotile
+
+import (
+	"image"
+	"sync"
+)
+
+// window is an Outline that holds LandData for some rectangle in memory, prefetched
+// from a RegionOutline (if supported). Anything outside of the window is fetched
+// from the underlying Outline as normal.
+// We also compute (lazily) data that is shared between tiles in the window,
+// like the distance to water.
+type window struct {
+	o      Outline
+	bounds image.Rectangle
+	data   [][]LandData
+
+	// padding around the region we were asked for
+	pad int
+
+	shoreOnce  sync.Once
+	shoreField *distanceField
+
+	landOnce  sync.Once
+	landField *distanceField
+}
+
+// shore returns the distance to water field for this window
+func (w *window) shore() *distanceField {
+	w.shoreOnce.Do(func() {
+		w.shoreField = newDistanceField(w, w.bounds, w.pad, isWater)
+	})
+	return w.shoreField
+}
+
+// land returns the distance to land (not water) field for this window
+func (w *window) land() *distanceField {
+	w.landOnce.Do(func() {
+		w.landField = newDistanceField(w, w.bounds, w.pad, isDry)
+	})
+	return w.landField
+}
+
+// LandAt returns the cached LandData at (x,y) if we have it
+func (w *window) LandAt(x, y int) LandData {
+	if !image.Pt(x, y).In(w.bounds) {
+		return w.o.LandAt(x, y)
+	}
+
+	iy := y - w.bounds.Min.Y
+	ix := x - w.bounds.Min.X
+	if iy >= len(w.data) || ix >= len(w.data[iy]) {
+		return w.o.LandAt(x, y) // the RegionOutline gave us less than we asked for
+	}
+
+	return w.data[iy][ix]
+}
+
+// windowPadding returns how far outside of a tile we look when placing it
+func (a *Autotiler) windowPadding() int {
+	pad := 2 // cardinals (& theirs, for cliff tops)
+	if shore := a.maxShore() + 1; shore > pad {
+		pad = shore // distance to water
+	}
+	if a.cfg.DeepWaterDistance > pad {
+		pad = a.cfg.DeepWaterDistance // distance to land
+	}
+	if a.cfg.DockLength > 0 && a.cfg.DockLength+2 > pad {
+		pad = a.cfg.DockLength + 2 // road end & it's neighbours, from a dock's neighbours
+	}
+	return pad
+}
+
+// prefetch returns a window over `region` (padded by the distance we look out
+// from any tile). If the given Outline supports fetching in bulk (see RegionOutline)
+// the data for the window is fetched now & held in memory.
+func (a *Autotiler) prefetch(o Outline, region image.Rectangle) Outline {
+	pad := a.windowPadding()
+	bounds := region.Inset(-pad)
+
+	if w, ok := o.(*window); ok {
+		if bounds.In(w.bounds) {
+			return w // we already have this
+		}
+		o = w.o
+	}
+
+	w := &window{o: o, bounds: bounds, pad: pad}
+	if ro, ok := o.(RegionOutline); ok {
+		w.data = ro.LandRect(bounds)
+	}
+
+	return w
+}
+
+// LandRect returns the LandData in the given rectangle, from the underlying Outline
+// if it supports it or else by calling LandAt for each tile. Either way
+// we only take our lock once.
+func (l *lockedOutline) LandRect(r image.Rectangle) [][]LandData {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if ro, ok := l.o.(RegionOutline); ok {
+		return ro.LandRect(r)
+	}
+
+	rows := make([][]LandData, r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := make([]LandData, r.Dx())
+		for x := r.Min.X; x < r.Max.X; x++ {
+			row[x-r.Min.X] = l.o.LandAt(x, y)
+		}
+		rows[y-r.Min.Y] = row
+	}
+	return rows
+}